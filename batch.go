@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/bfontaine/leonard/leonard"
+)
+
+// isImage tests if a file found in a directory in batch mode should be
+// processed, based on its extension.
+func isImage(filename string) bool {
+	ext := strings.ToLower(filepath.Ext(filename))
+	for _, f := range leonard.Formats() {
+		if f.Decode == nil {
+			continue
+		}
+		for _, e := range f.Extensions {
+			if e == ext {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// batch applies a pipeline on many images
+type batch struct {
+	p  *pipeline
+	so saveOptions
+
+	outputDir string
+	// template of the output filenames; see outputPath
+	template     string
+	workers      int
+	skipExisting bool
+
+	progress io.Writer
+}
+
+type batchResult struct {
+	input, output string
+	skipped       bool
+	err           error
+}
+
+// expandInputs returns the sorted list of files matched by the given globs or
+// directories.
+func expandInputs(patterns []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var files []string
+
+	add := func(f string) {
+		if !seen[f] {
+			seen[f] = true
+			files = append(files, f)
+		}
+	}
+
+	for _, pattern := range patterns {
+		if fi, err := os.Stat(pattern); err == nil && fi.IsDir() {
+			entries, err := ioutil.ReadDir(pattern)
+			if err != nil {
+				return nil, err
+			}
+			for _, e := range entries {
+				if !e.IsDir() && isImage(e.Name()) {
+					add(filepath.Join(pattern, e.Name()))
+				}
+			}
+			continue
+		}
+
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", pattern, err)
+		}
+		if matches == nil {
+			return nil, fmt.Errorf("%s: no such file", pattern)
+		}
+		for _, m := range matches {
+			add(m)
+		}
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// outputPath returns the output path for an input file. "{name}" in the
+// template is replaced by the input's filename without its extension and
+// "{ext}" by its extension, including the dot.
+func (b *batch) outputPath(input string) string {
+	base := filepath.Base(input)
+	ext := filepath.Ext(base)
+
+	r := strings.NewReplacer(
+		"{name}", strings.TrimSuffix(base, ext),
+		"{ext}", ext)
+
+	return filepath.Join(b.outputDir, r.Replace(b.template))
+}
+
+func (b *batch) process(input string) (res batchResult) {
+	res = batchResult{input: input, output: b.outputPath(input)}
+
+	// Don't let one bad image abort the whole batch
+	defer func() {
+		if r := recover(); r != nil {
+			res.err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	if b.skipExisting {
+		if _, err := os.Stat(res.output); err == nil {
+			res.skipped = true
+			return res
+		}
+	}
+
+	res.err = b.p.run(input, res.output, b.so)
+	return res
+}
+
+// checkOutputs ensures no two inputs are written to the same output file and
+// no input is overwritten by an output. Paths are compared once made absolute.
+func (b *batch) checkOutputs(inputs []string) error {
+	absInputs := make(map[string]string, len(inputs))
+	for _, input := range inputs {
+		abs, err := filepath.Abs(input)
+		if err != nil {
+			return err
+		}
+		absInputs[abs] = input
+	}
+
+	outputs := make(map[string]string, len(inputs))
+	for _, input := range inputs {
+		output := b.outputPath(input)
+		abs, err := filepath.Abs(output)
+		if err != nil {
+			return err
+		}
+		if other, ok := absInputs[abs]; ok {
+			return fmt.Errorf("%s would be overwritten by the output of %s", other, input)
+		}
+		if other, ok := outputs[abs]; ok {
+			return fmt.Errorf("%s and %s would both be written to %s", other, input, output)
+		}
+		outputs[abs] = input
+	}
+	return nil
+}
+
+// run processes all the files and returns the failed ones. A failure doesn't
+// stop the other files from being processed.
+func (b *batch) run(inputs []string) []batchResult {
+	jobs := make(chan string)
+	results := make(chan batchResult)
+
+	var wg sync.WaitGroup
+	for i := 0; i < b.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for input := range jobs {
+				results <- b.process(input)
+			}
+		}()
+	}
+
+	go func() {
+		for _, input := range inputs {
+			jobs <- input
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	var failures []batchResult
+	done, skipped := 0, 0
+
+	for res := range results {
+		done++
+		status := "ok"
+		switch {
+		case res.err != nil:
+			status = "failed"
+			failures = append(failures, res)
+		case res.skipped:
+			status = "skipped"
+			skipped++
+		}
+		fmt.Fprintf(b.progress, "[%d/%d] %s: %s\n", done, len(inputs), res.input, status)
+	}
+
+	fmt.Fprintf(b.progress, "%d processed, %d skipped, %d failed\n",
+		len(inputs)-skipped-len(failures), skipped, len(failures))
+
+	for _, f := range failures {
+		fmt.Fprintf(b.progress, "  %s: %s\n", f.input, f.err)
+	}
+
+	return failures
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"image"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bfontaine/leonard/leonard"
+)
+
+// tempDir creates a temporary directory removed at the end of the test
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "leonard")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+// writeImages writes small images with the given names in a directory
+func writeImages(t *testing.T, dir string, names ...string) {
+	for _, name := range names {
+		if err := leonard.SaveImage(image.NewGray(image.Rect(0, 0, 4, 4)), filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestOutputPath(t *testing.T) {
+	for _, tt := range []struct {
+		dir, template, input string
+		want                 string
+	}{
+		{"out", "{name}{ext}", "in/photo.jpg", "out/photo.jpg"},
+		{"out", "{name}-small.png", "in/photo.jpg", "out/photo-small.png"},
+		{"out", "{name}{ext}", "archive.tar.gz", "out/archive.tar.gz"},
+		{"out", "{name}.{name}{ext}", "noext", "out/noext.noext"},
+		{".", "x-{name}{ext}", "/abs/a.png", "x-a.png"},
+	} {
+		b := &batch{outputDir: tt.dir, template: tt.template}
+		if got := b.outputPath(tt.input); got != filepath.FromSlash(tt.want) {
+			t.Errorf("%s with %s: got %s, want %s", tt.input, tt.template, got, tt.want)
+		}
+	}
+}
+
+func TestExpandInputs(t *testing.T) {
+	dir := tempDir(t)
+	writeImages(t, dir, "b.png", "a.jpg", "c.PNG")
+	if err := ioutil.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sub.png"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	join := func(names ...string) string {
+		for i, name := range names {
+			names[i] = filepath.Join(dir, name)
+		}
+		return strings.Join(names, ",")
+	}
+
+	for _, tt := range []struct {
+		patterns []string
+		want     string
+	}{
+		// directories are listed without subdirectories nor non-images
+		{[]string{dir}, join("a.jpg", "b.png", "c.PNG")},
+		// globs match any file
+		{[]string{filepath.Join(dir, "*.txt")}, join("notes.txt")},
+		// files matched twice are only processed once
+		{[]string{filepath.Join(dir, "b.png"), dir}, join("a.jpg", "b.png", "c.PNG")},
+	} {
+		files, err := expandInputs(tt.patterns)
+		if err != nil {
+			t.Errorf("%v: %s", tt.patterns, err)
+			continue
+		}
+		if got := strings.Join(files, ","); got != tt.want {
+			t.Errorf("%v: got %s, want %s", tt.patterns, got, tt.want)
+		}
+	}
+
+	for _, pattern := range []string{filepath.Join(dir, "missing.png"), filepath.Join(dir, "[")} {
+		if _, err := expandInputs([]string{pattern}); err == nil {
+			t.Errorf("%s: expected an error", pattern)
+		}
+	}
+}
+
+func TestCheckOutputs(t *testing.T) {
+	dir := tempDir(t)
+	in := filepath.Join(dir, "in")
+	out := filepath.Join(dir, "out")
+
+	for _, tt := range []struct {
+		name      string
+		outputDir string
+		template  string
+		inputs    []string
+		ok        bool
+	}{
+		{"distinct", out, "{name}{ext}", []string{in + "/a.png", in + "/b.png"}, true},
+		{"collision", out, "{name}.png", []string{in + "/a.png", in + "/a.jpg"}, false},
+		{"overwrite", in, "{name}{ext}", []string{in + "/a.png"}, false},
+		{"overwrite with another path", in, "{name}{ext}", []string{in + "/../in/a.png"}, false},
+		{"overwrite another input", in, "{name}.png", []string{in + "/a.jpg", in + "/a.png"}, false},
+		{"same directory", in, "{name}-small{ext}", []string{in + "/a.png"}, true},
+	} {
+		b := &batch{outputDir: tt.outputDir, template: tt.template}
+		if err := b.checkOutputs(tt.inputs); (err == nil) != tt.ok {
+			t.Errorf("%s: got error %v", tt.name, err)
+		}
+	}
+}
+
+func TestBatchSkipExisting(t *testing.T) {
+	in, out := tempDir(t), tempDir(t)
+	writeImages(t, in, "a.png", "b.png")
+
+	// a leftover from a previous run
+	existing := filepath.Join(out, "a.png")
+	if err := ioutil.WriteFile(existing, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := pipelineFromSpecs([]string{"gray"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	inputs := []string{filepath.Join(in, "a.png"), filepath.Join(in, "b.png")}
+
+	for _, tt := range []struct {
+		skipExisting bool
+		old          bool
+	}{
+		{true, true},
+		{false, false},
+	} {
+		b := &batch{
+			p:            p,
+			outputDir:    out,
+			template:     "{name}{ext}",
+			workers:      2,
+			skipExisting: tt.skipExisting,
+			progress:     ioutil.Discard,
+		}
+		if failures := b.run(inputs); len(failures) > 0 {
+			t.Fatalf("skipExisting=%v: got failures %v", tt.skipExisting, failures)
+		}
+
+		data, err := ioutil.ReadFile(existing)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if old := string(data) == "old"; old != tt.old {
+			t.Errorf("skipExisting=%v: got the old file: %v", tt.skipExisting, old)
+		}
+		if _, err := leonard.LoadImage(filepath.Join(out, "b.png")); err != nil {
+			t.Errorf("skipExisting=%v: %s", tt.skipExisting, err)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package leonard
+
+import (
+	"image"
+	"image/color"
+)
+
+// combine creates a new image by combining two images pixel per pixel. fn is
+// called on the red, green and blue channels and alphaFn on the alpha one;
+// both take values in the 0-0xFFFF range. The result has the bounds of a.
+func combine(a, b image.Image, fn, alphaFn func(ca, cb float64) float64) image.Image {
+	bounds := a.Bounds()
+	combined := newImageLike(bounds, a, b)
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			ra, ga, ba, aa := a.At(x, y).RGBA()
+			rb, gb, bb, ab := b.At(x, y).RGBA()
+
+			alpha := clamp16(alphaFn(float64(aa), float64(ab)))
+
+			// Colors are alpha-premultiplied
+			channel := func(ca, cb uint32) uint16 {
+				v := clamp16(fn(float64(ca), float64(cb)))
+				if v > alpha {
+					v = alpha
+				}
+				return uint16(v)
+			}
+
+			combined.Set(x, y, color.RGBA64{
+				channel(ra, rb),
+				channel(ga, gb),
+				channel(ba, bb),
+				uint16(alpha),
+			})
+		}
+	}
+
+	return combined
+}
+
+func keepAlpha(ca, _ float64) float64 { return ca }
+
+// Add returns the sum of two images. Values are clamped.
+func Add(a, b image.Image) image.Image {
+	return combine(a, b, func(ca, cb float64) float64 {
+		return ca + cb
+	}, keepAlpha)
+}
+
+// Subtract subtracts b from a. Negative values are clamped to 0.
+func Subtract(a, b image.Image) image.Image {
+	return combine(a, b, func(ca, cb float64) float64 {
+		return ca - cb
+	}, keepAlpha)
+}
+
+// Multiply multiplies two images, with channel values taken in the 0-1 range.
+func Multiply(a, b image.Image) image.Image {
+	return combine(a, b, func(ca, cb float64) float64 {
+		return ca * cb / 0xFFFF
+	}, keepAlpha)
+}
+
+// Blend returns a linear interpolation between two images. An alpha of 0 gives
+// a and an alpha of 1 gives b.
+func Blend(a, b image.Image, alpha float64) image.Image {
+	mix := func(ca, cb float64) float64 {
+		return (1-alpha)*ca + alpha*cb
+	}
+	return combine(a, b, mix, mix)
+}
+
+// Mask applies a mask on an image: the luminance of each pixel of the mask is
+// used as the opacity of the corresponding pixel in the image. Black areas of
+// the mask become transparent.
+func Mask(img, mask image.Image) image.Image {
+	bounds := img.Bounds()
+	masked := newColorImageLike(bounds, img)
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			// alpha-premultiplied colors: scaling all channels scales the
+			// opacity.
+			opacity := float64(luminance(mask.At(x, y))) / 0xFFFF
+			if opacity > 1 {
+				opacity = 1
+			}
+
+			masked.Set(x, y, color.RGBA64{
+				uint16(float64(r) * opacity),
+				uint16(float64(g) * opacity),
+				uint16(float64(b) * opacity),
+				uint16(float64(a) * opacity),
+			})
+		}
+	}
+
+	return masked
+}
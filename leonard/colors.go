@@ -3,6 +3,9 @@ package leonard
 import (
 	"image"
 	"image/color"
+	"image/draw"
+	"math"
+	"sort"
 )
 
 var (
@@ -10,11 +13,6 @@ var (
 	white = color.Gray{0xff}
 )
 
-// Convert a value in a 0-0xFFFF range into one in a 0-0xFF one
-func to255(n float64) float64 {
-	return n * 0xFF / 0xFFFF
-}
-
 func luminanceRGB(r, g, b uint32) float32 {
 	// Ref:
 	// https://en.wikipedia.org/wiki/Grayscale#Converting_color_to_grayscale
@@ -26,6 +24,150 @@ func luminance(c color.Color) float32 {
 	return luminanceRGB(r, g, b)
 }
 
+// hsvToRGB converts a color from HSV to RGB. The hue is in degrees; the
+// saturation and the value are in the 0-1 range.
+func hsvToRGB(h, s, v float64) color.RGBA64 {
+	// https://en.wikipedia.org/wiki/HSL_and_HSV#From_HSV
+	h = math.Mod(h, 360)
+	if h < 0 {
+		h += 360
+	}
+
+	c := v * s
+	hp := h / 60
+	x := c * (1 - math.Abs(math.Mod(hp, 2)-1))
+
+	var r, g, b float64
+	switch {
+	case hp < 1:
+		r, g, b = c, x, 0
+	case hp < 2:
+		r, g, b = x, c, 0
+	case hp < 3:
+		r, g, b = 0, c, x
+	case hp < 4:
+		r, g, b = 0, x, c
+	case hp < 5:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+
+	m := v - c
+	return color.RGBA64{
+		uint16((r + m) * 0xFFFF),
+		uint16((g + m) * 0xFFFF),
+		uint16((b + m) * 0xFFFF),
+		0xFFFF,
+	}
+}
+
+// rgbToLab converts a color from sRGB to CIE L*a*b* with a D65 white point. L
+// is in the 0-100 range; a and b are roughly in the -128-127 one.
+func rgbToLab(c color.Color) (l, a, b float64) {
+	// https://en.wikipedia.org/wiki/SRGB#From_sRGB_to_CIE_XYZ
+	// https://en.wikipedia.org/wiki/CIELAB_color_space#From_CIEXYZ_to_CIELAB
+	nc := color.NRGBA64Model.Convert(c).(color.NRGBA64)
+
+	linear := func(v uint16) float64 {
+		f := float64(v) / 0xFFFF
+		if f <= 0.04045 {
+			return f / 12.92
+		}
+		return math.Pow((f+0.055)/1.055, 2.4)
+	}
+	r, g, bl := linear(nc.R), linear(nc.G), linear(nc.B)
+
+	x := (0.4124*r + 0.3576*g + 0.1805*bl) / 0.95047
+	y := 0.2126*r + 0.7152*g + 0.0722*bl
+	z := (0.0193*r + 0.1192*g + 0.9505*bl) / 1.08883
+
+	f := func(t float64) float64 {
+		if t > 216.0/24389 {
+			return math.Cbrt(t)
+		}
+		return t*24389/(27*116) + 16.0/116
+	}
+	fx, fy, fz := f(x), f(y), f(z)
+
+	return 116*fy - 16, 500 * (fx - fy), 200 * (fy - fz)
+}
+
+// is16Bit tests if an image has 16 bits per channel
+func is16Bit(img image.Image) bool {
+	switch img.ColorModel() {
+	case color.Gray16Model, color.RGBA64Model, color.NRGBA64Model:
+		return true
+	}
+	return false
+}
+
+func isGray(img image.Image) bool {
+	m := img.ColorModel()
+	return m == color.GrayModel || m == color.Gray16Model
+}
+
+// newImageLike returns a new image with the given bounds that can hold the
+// colors of all the given images without losing precision: it's 16-bit if at
+// least one of them is and grayscale if all of them are.
+func newImageLike(r image.Rectangle, imgs ...image.Image) draw.Image {
+	wide, gray := false, true
+	for _, img := range imgs {
+		wide = wide || is16Bit(img)
+		gray = gray && isGray(img)
+	}
+
+	switch {
+	case gray && wide:
+		return image.NewGray16(r)
+	case gray:
+		return image.NewGray(r)
+	case wide:
+		return image.NewRGBA64(r)
+	default:
+		return image.NewRGBA(r)
+	}
+}
+
+// newColorImageLike is like newImageLike but always returns a colored image
+// with an alpha channel.
+func newColorImageLike(r image.Rectangle, img image.Image) draw.Image {
+	if is16Bit(img) {
+		return image.NewRGBA64(r)
+	}
+	return image.NewRGBA(r)
+}
+
+// To16Bit converts an image to 16 bits per channel, so that the transforms
+// applied on it keep their precision. Grayscale images stay grayscale.
+func To16Bit(img image.Image) image.Image {
+	bounds := img.Bounds()
+
+	var converted draw.Image
+	if isGray(img) {
+		converted = image.NewGray16(bounds)
+	} else {
+		converted = image.NewRGBA64(bounds)
+	}
+	draw.Draw(converted, bounds, img, bounds.Min, draw.Src)
+	return converted
+}
+
+// To8Bit converts an image to 8 bits per channel. Grayscale images stay
+// grayscale.
+func To8Bit(img image.Image) image.Image {
+	bounds := img.Bounds()
+
+	var converted draw.Image
+	if isGray(img) {
+		converted = image.NewGray(bounds)
+	} else {
+		converted = image.NewRGBA(bounds)
+	}
+	draw.Draw(converted, bounds, img, bounds.Min, draw.Src)
+	return converted
+}
+
 // BinaryImage is a black & white image represented as a boolean matrix.
 //
 // White represents true pixels and black represents false ones. The underlying
@@ -62,6 +204,7 @@ func (b *BinaryImage) Set(x, y int, value bool) {
 
 	if !value {
 		delete(b.pixels, p)
+		return
 	}
 
 	b.pixels[p] = value
@@ -90,6 +233,22 @@ func (b *BinaryImage) EachPixel(fn func(x, y int)) {
 	}
 }
 
+// Points returns the truthy pixels, from top to bottom and left to right
+func (b *BinaryImage) Points() []image.Point {
+	points := make([]image.Point, 0, len(b.pixels))
+	for p := range b.pixels {
+		points = append(points, p)
+	}
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].Y != points[j].Y {
+			return points[i].Y < points[j].Y
+		}
+		return points[i].X < points[j].X
+	})
+	return points
+}
+
+// Clone returns a copy of the image
 func (b *BinaryImage) Clone() *BinaryImage {
 	b2 := NewEmptyBinaryImage(b.height, b.width)
 	for p, v := range b.pixels {
@@ -0,0 +1,75 @@
+package leonard
+
+import (
+	"image"
+	"testing"
+)
+
+func TestBinaryImageSetFalse(t *testing.T) {
+	b := NewEmptyBinaryImage(4, 4)
+	b.Set(1, 1, true)
+	b.Set(2, 1, true)
+	b.Set(1, 1, false)
+	// clearing a pixel that isn't set is a no-op
+	b.Set(3, 3, false)
+
+	if b.Get(1, 1) || !b.Get(2, 1) || b.Get(3, 3) {
+		t.Errorf("got %v %v %v, want false true false", b.Get(1, 1), b.Get(2, 1), b.Get(3, 3))
+	}
+
+	// cleared pixels aren't truthy, so they must not be visited
+	var visited []image.Point
+	b.EachPixel(func(x, y int) {
+		visited = append(visited, image.Point{x, y})
+	})
+	if len(visited) != 1 || visited[0] != (image.Point{2, 1}) {
+		t.Errorf("EachPixel visited %v, want [(2,1)]", visited)
+	}
+	if points := b.Points(); len(points) != 1 || points[0] != (image.Point{2, 1}) {
+		t.Errorf("got points %v, want [(2,1)]", points)
+	}
+}
+
+func TestBinaryImageInvert(t *testing.T) {
+	b := NewEmptyBinaryImage(2, 3)
+	b.Set(0, 0, true)
+	b.Invert()
+
+	if got := len(b.Points()); got != 5 {
+		t.Errorf("got %d white pixels, want 5", got)
+	}
+	if b.Get(0, 0) {
+		t.Error("(0, 0) is still white")
+	}
+}
+
+func TestBinaryImagePointsOrder(t *testing.T) {
+	b := NewEmptyBinaryImage(3, 3)
+	for _, p := range []image.Point{{2, 2}, {0, 1}, {1, 0}, {2, 1}} {
+		b.Set(p.X, p.Y, true)
+	}
+
+	want := []image.Point{{1, 0}, {0, 1}, {2, 1}, {2, 2}}
+	got := b.Points()
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestBinaryImageClone(t *testing.T) {
+	b := NewEmptyBinaryImage(2, 2)
+	b.Set(1, 1, true)
+
+	c := b.Clone()
+	c.Set(1, 1, false)
+	c.Set(0, 0, true)
+
+	if !b.Get(1, 1) || b.Get(0, 0) {
+		t.Error("modifying the clone modified the original image")
+	}
+}
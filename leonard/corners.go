@@ -0,0 +1,332 @@
+package leonard
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"sort"
+)
+
+// Keypoint is a point of interest of an image, e.g. a corner
+type Keypoint struct {
+	X, Y int
+	// Score is the strength of the keypoint. Its scale depends on the
+	// detector; only scores from the same detector can be compared.
+	Score float64
+	// Angle is the orientation of the keypoint in radians, set by Describe.
+	// 0 points to the right and π/2 to the bottom.
+	Angle float64
+}
+
+// CornerDetector is an algorithm that finds the corners of an image
+type CornerDetector int
+
+const (
+	// Harris looks for pixels where the gradients are strong in two
+	// directions. Its response is det(M) - k·trace(M)², where M is the
+	// structure tensor of the gradients around the pixel.
+	Harris CornerDetector = iota
+	// ShiTomasi is a variant of Harris whose response is the smallest
+	// eigenvalue of the structure tensor. It doesn't need a sensitivity
+	// parameter and gives more stable corners.
+	ShiTomasi
+	// FAST compares each pixel to the 16 pixels of a circle around it: it's a
+	// corner if a long enough arc of the circle is brighter or darker. It's
+	// much faster than Harris but more sensitive to noise.
+	FAST
+)
+
+const (
+	defaultCornerSigma   = 1.0
+	defaultHarrisK       = 0.04
+	defaultFASTContrast  = 0.1
+	defaultFASTArc       = 9
+	defaultCornerQuality = 0.01
+	defaultCornerRadius  = 3
+)
+
+// CornerOptions are the options of the corner detectors. A nil
+// *CornerOptions means the defaults are used.
+type CornerOptions struct {
+	// The default is Harris
+	Detector CornerDetector
+
+	// Operator is the gradient operator used by Harris and ShiTomasi
+	Operator GradientOperator
+	// Sigma is the standard deviation of the gaussian window over which
+	// Harris and ShiTomasi sum the gradients; the default is 1.
+	Sigma float64
+	// K is the sensitivity of Harris, usually between 0.04 and 0.06; the
+	// default is 0.04. Higher values find less corners.
+	K float64
+
+	// Contrast is the minimal difference of luminance, between 0 and 1, for
+	// FAST to consider a pixel of the circle brighter or darker than the
+	// center; the default is 0.1.
+	Contrast float64
+	// Arc is the number of contiguous pixels of the circle, between 9 and 16,
+	// that must be all brighter or all darker for FAST; the default is 9.
+	Arc int
+
+	// Quality drops the corners whose response is less than this fraction of
+	// the strongest one; the default is 0.01.
+	Quality float64
+	// Radius is the radius of the non-maximum suppression: a corner is only
+	// kept if it has the highest response of the (2·Radius+1)² square around
+	// it. The default is 3.
+	Radius int
+	// Max is the maximum number of corners; 0 means no limit
+	Max int
+}
+
+// withDefaults returns a copy of the options with the default values set, or
+// an error if they're invalid.
+func (o *CornerOptions) withDefaults() (CornerOptions, error) {
+	var opts CornerOptions
+	if o != nil {
+		opts = *o
+	}
+
+	if opts.Sigma <= 0 {
+		opts.Sigma = defaultCornerSigma
+	}
+	if opts.K <= 0 {
+		opts.K = defaultHarrisK
+	}
+	if opts.Contrast <= 0 {
+		opts.Contrast = defaultFASTContrast
+	}
+	if opts.Arc == 0 {
+		opts.Arc = defaultFASTArc
+	}
+	if opts.Quality <= 0 {
+		opts.Quality = defaultCornerQuality
+	}
+	if opts.Radius <= 0 {
+		opts.Radius = defaultCornerRadius
+	}
+
+	switch opts.Detector {
+	case Harris, ShiTomasi, FAST:
+	default:
+		return opts, fmt.Errorf("invalid corner detector: %d", opts.Detector)
+	}
+	if opts.Arc < 9 || opts.Arc > 16 {
+		return opts, fmt.Errorf("invalid FAST arc: %d", opts.Arc)
+	}
+	return opts, nil
+}
+
+// CornerResponse returns the response of a corner detector at each pixel of
+// an image: the higher, the more likely the pixel is a corner. Harris gives
+// negative responses on edges; FAST gives 0 on the pixels that aren't corners.
+//
+// An error is returned if the options are invalid.
+func CornerResponse(img image.Image, opts *CornerOptions) (*FloatImage, error) {
+	o, err := opts.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+
+	if o.Detector == FAST {
+		return fastResponse(img, &o), nil
+	}
+	return structureTensorResponse(img, &o)
+}
+
+// Corners returns the corners of an image, strongest first. An error is
+// returned if the options are invalid.
+func Corners(img image.Image, opts *CornerOptions) ([]Keypoint, error) {
+	o, err := opts.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := CornerResponse(img, &o)
+	if err != nil {
+		return nil, err
+	}
+	_, max := response.MinMax()
+	if max <= 0 {
+		// e.g. a flat image
+		return nil, nil
+	}
+
+	keypoints := localMaxima(response, float64(max)*o.Quality, o.Radius)
+	if o.Max > 0 && len(keypoints) > o.Max {
+		keypoints = keypoints[:o.Max]
+	}
+	return keypoints, nil
+}
+
+// structureTensorResponse computes the response of Harris or ShiTomasi
+func structureTensorResponse(img image.Image, o *CornerOptions) (*FloatImage, error) {
+	// Harris & Stephens, "A Combined Corner and Edge Detector" (1988)
+	// Shi & Tomasi, "Good Features to Track" (1994)
+	g, err := NewGradientFieldWithOptions(img, &GradientOptions{Operator: o.Operator})
+	if err != nil {
+		return nil, err
+	}
+	bounds := g.Gx.Rect
+
+	// The structure tensor M = | Ix²  IxIy |, summed over a gaussian window
+	//                          | IxIy Iy²  |
+	tensor := NewFloatImage(bounds, 3)
+	for i, gx := range g.Gx.Pix {
+		gy := g.Gy.Pix[i]
+		tensor.Pix[3*i] = gx * gx
+		tensor.Pix[3*i+1] = gx * gy
+		tensor.Pix[3*i+2] = gy * gy
+	}
+	tensor = gaussianFilterFloat(tensor, o.Sigma)
+
+	response := NewFloatImage(bounds, 1)
+	for i := range response.Pix {
+		a := float64(tensor.Pix[3*i])
+		b := float64(tensor.Pix[3*i+1])
+		c := float64(tensor.Pix[3*i+2])
+
+		var r float64
+		if o.Detector == ShiTomasi {
+			r = (a+c)/2 - math.Sqrt((a-c)*(a-c)/4+b*b)
+		} else {
+			r = a*c - b*b - o.K*(a+c)*(a+c)
+		}
+		response.Pix[i] = float32(r)
+	}
+
+	return response, nil
+}
+
+// fastCircle is the circle of 16 pixels around the center used by FAST, in
+// clockwise order from the top.
+var fastCircle = []offset{
+	{0, -3}, {1, -3}, {2, -2}, {3, -1},
+	{3, 0}, {3, 1}, {2, 2}, {1, 3},
+	{0, 3}, {-1, 3}, {-2, 2}, {-3, 1},
+	{-3, 0}, {-3, -1}, {-2, -2}, {-1, -3},
+}
+
+// fastResponse computes the response of FAST. The score of a corner is the
+// sum of the differences beyond the contrast between the center and the
+// pixels of the circle on its brighter or darker side, whichever is higher.
+func fastResponse(img image.Image, o *CornerOptions) *FloatImage {
+	// Rosten & Drummond, "Machine learning for high-speed corner detection"
+	// (2006)
+	lum := NewFloatImageFrom(img, 1)
+	bounds := lum.Rect
+	response := NewFloatImage(bounds, 1)
+	t := float32(o.Contrast)
+
+	var classes [16]int
+	for y := bounds.Min.Y + 3; y < bounds.Max.Y-3; y++ {
+		for x := bounds.Min.X + 3; x < bounds.Max.X-3; x++ {
+			center := lum.FloatAt(x, y, 0)
+
+			var bright, dark float32
+			for k, p := range fastCircle {
+				d := lum.FloatAt(x+p.X, y+p.Y, 0) - center
+				switch {
+				case d > t:
+					classes[k] = 1
+					bright += d - t
+				case d < -t:
+					classes[k] = -1
+					dark += -d - t
+				default:
+					classes[k] = 0
+				}
+			}
+
+			// Longest arc of pixels of the same class; the circle is looked
+			// at twice to find the arcs that wrap around.
+			longest, run := 0, 0
+			for k := 0; k < 2*len(classes) && longest < len(classes); k++ {
+				c := classes[k%len(classes)]
+				if c != 0 && k > 0 && c == classes[(k-1)%len(classes)] {
+					run++
+				} else if c != 0 {
+					run = 1
+				} else {
+					run = 0
+				}
+				if run > longest {
+					longest = run
+				}
+			}
+
+			if longest >= o.Arc {
+				response.SetFloat(x, y, 0, float32(math.Max(float64(bright), float64(dark))))
+			}
+		}
+	}
+
+	return response
+}
+
+// localMaxima returns the pixels of the first channel of f whose value is at
+// least threshold and the highest of the (2·radius+1)² square around them,
+// highest first. On plateaus only the first pixel is kept.
+func localMaxima(f *FloatImage, threshold float64, radius int) []Keypoint {
+	bounds := f.Rect
+	var keypoints []Keypoint
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			v := f.FloatAt(x, y, 0)
+			if float64(v) < threshold {
+				continue
+			}
+
+			maximum := true
+			for ny := y - radius; ny <= y+radius && maximum; ny++ {
+				for nx := x - radius; nx <= x+radius; nx++ {
+					if !(image.Point{nx, ny}.In(bounds)) || (nx == x && ny == y) {
+						continue
+					}
+					nv := f.FloatAt(nx, ny, 0)
+					// pixels before this one win the ties
+					before := ny < y || (ny == y && nx < x)
+					if nv > v || (nv == v && before) {
+						maximum = false
+						break
+					}
+				}
+			}
+
+			if maximum {
+				keypoints = append(keypoints, Keypoint{X: x, Y: y, Score: float64(v)})
+			}
+		}
+	}
+
+	sort.SliceStable(keypoints, func(i, j int) bool {
+		return keypoints[i].Score > keypoints[j].Score
+	})
+	return keypoints
+}
+
+// DrawKeypoints draws a circle of the given color around each keypoint over an
+// image.
+func DrawKeypoints(img image.Image, keypoints []Keypoint, c color.Color) image.Image {
+	const radius = 4
+
+	bounds := img.Bounds()
+	out := newColorImageLike(bounds, img)
+	draw.Draw(out, bounds, img, bounds.Min, draw.Src)
+
+	for _, k := range keypoints {
+		for dy := -radius; dy <= radius; dy++ {
+			for dx := -radius; dx <= radius; dx++ {
+				d := math.Hypot(float64(dx), float64(dy))
+				if math.Abs(d-radius) < 0.5 {
+					out.Set(k.X+dx, k.Y+dy, c)
+				}
+			}
+		}
+	}
+
+	return out
+}
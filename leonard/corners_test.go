@@ -0,0 +1,114 @@
+package leonard
+
+import (
+	"image"
+	"testing"
+)
+
+// square returns a 40×40 black image with a white square from (10, 10) to
+// (30, 30).
+func square() *image.Gray {
+	return newGrayImage(40, 40, func(x, y int) uint8 {
+		if x >= 10 && x < 30 && y >= 10 && y < 30 {
+			return 255
+		}
+		return 0
+	})
+}
+
+func TestCorners(t *testing.T) {
+	want := []image.Point{{10, 10}, {29, 10}, {10, 29}, {29, 29}}
+
+	for _, detector := range []CornerDetector{Harris, ShiTomasi, FAST} {
+		corners, err := Corners(square(), &CornerOptions{Detector: detector, Quality: 0.1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(corners) != 4 {
+			t.Errorf("detector %d: got %d corners, want 4: %v", detector, len(corners), corners)
+			continue
+		}
+
+		for _, w := range want {
+			found := false
+			for _, c := range corners {
+				if absint(c.X-w.X) <= 2 && absint(c.Y-w.Y) <= 2 {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("detector %d: no corner around %v in %v", detector, w, corners)
+			}
+		}
+
+		for i := 1; i < len(corners); i++ {
+			if corners[i].Score > corners[i-1].Score {
+				t.Errorf("detector %d: the corners aren't sorted", detector)
+			}
+		}
+	}
+}
+
+func TestCornersMax(t *testing.T) {
+	corners, err := Corners(square(), &CornerOptions{Max: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(corners) != 2 {
+		t.Errorf("got %d corners, want 2", len(corners))
+	}
+}
+
+func TestCornersFlatImage(t *testing.T) {
+	for _, detector := range []CornerDetector{Harris, ShiTomasi, FAST} {
+		corners, err := Corners(uniformGray(20, 20, 100), &CornerOptions{Detector: detector})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(corners) != 0 {
+			t.Errorf("detector %d: got corners %v on a flat image", detector, corners)
+		}
+	}
+}
+
+func TestCornerResponseHarrisEdges(t *testing.T) {
+	response, err := CornerResponse(square(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Harris is negative on edges and positive on corners
+	if v := response.FloatAt(20, 10, 0); v >= 0 {
+		t.Errorf("got %f on an edge, want a negative response", v)
+	}
+	if v := response.FloatAt(10, 10, 0); v <= 0 {
+		t.Errorf("got %f on a corner, want a positive response", v)
+	}
+}
+
+func TestCornersInvalidOptions(t *testing.T) {
+	for _, opts := range []CornerOptions{
+		{Detector: 42},
+		{Detector: FAST, Arc: 8},
+		{Detector: FAST, Arc: 17},
+		{Detector: FAST, Arc: -1},
+		{Operator: 42},
+	} {
+		opts := opts
+		if _, err := Corners(square(), &opts); err == nil {
+			t.Errorf("%+v: expected an error from Corners", opts)
+		}
+		if _, err := CornerResponse(square(), &opts); err == nil {
+			t.Errorf("%+v: expected an error from CornerResponse", opts)
+		}
+	}
+}
+
+func TestCornersEmptyImage(t *testing.T) {
+	corners, err := Corners(image.NewGray(image.Rectangle{}), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(corners) != 0 {
+		t.Errorf("got %v, want no corner", corners)
+	}
+}
@@ -0,0 +1,241 @@
+package leonard
+
+import (
+	"image"
+	"math"
+)
+
+// DistanceMetric is a way to measure the distance between two pixels
+type DistanceMetric int
+
+const (
+	// EuclideanDistance is the exact Euclidean distance
+	EuclideanDistance DistanceMetric = iota
+	// ChamferDistance approximates the Euclidean distance with steps of 1
+	// between 4-neighbours and 4/3 between diagonal neighbours. It's faster
+	// to compute and differs from the Euclidean distance by up to 8%.
+	ChamferDistance
+	// CityBlockDistance is the Manhattan distance |dx|+|dy|
+	CityBlockDistance
+)
+
+// infiniteDistance is used for pixels whose distance isn't known yet. It's not
+// math.Inf(1) because it has to be subtracted from itself.
+const infiniteDistance = 1e20
+
+// DistanceTransform returns the distance from each pixel of the image to the
+// closest truthy one, called a feature. Features have a distance of 0. If
+// there's no feature all the distances are +Inf.
+//
+// Use the Image method of the result to get a Gray16 image.
+func (b *BinaryImage) DistanceTransform(metric DistanceMetric) *FloatImage {
+	distances, _ := b.NearestFeatures(metric)
+	return distances
+}
+
+// NearestFeatures is like DistanceTransform but also returns the nearest
+// feature of each pixel, in the same order as the samples of the distances:
+// the i-th point is the nearest feature of the pixel whose distance is Pix[i].
+// If there's no feature, all points are (-1, -1).
+func (b *BinaryImage) NearestFeatures(metric DistanceMetric) (*FloatImage, []image.Point) {
+	w, h := b.width, b.height
+
+	var d []float64
+	var nearest []int
+
+	switch metric {
+	case EuclideanDistance:
+		d, nearest = euclideanTransform(w, h, b.Get)
+		for i, v := range d {
+			d[i] = math.Sqrt(v)
+		}
+	case ChamferDistance:
+		d, nearest = chamferTransform(w, h, b.Get, 3, 4)
+	case CityBlockDistance:
+		d, nearest = chamferTransform(w, h, b.Get, 1, 0)
+	default:
+		panic("Invalid distance metric")
+	}
+
+	distances := NewFloatImage(b.Bounds(), 1)
+	points := make([]image.Point, len(d))
+
+	for i, v := range d {
+		if nearest[i] < 0 {
+			distances.Pix[i] = float32(math.Inf(1))
+			points[i] = image.Point{-1, -1}
+			continue
+		}
+		distances.Pix[i] = float32(v)
+		points[i] = image.Point{nearest[i] % w, nearest[i] / w}
+	}
+
+	return distances, points
+}
+
+// euclideanTransform returns the squared Euclidean distance from each pixel of
+// a w×h grid to the closest one for which feature returns true, row by row,
+// as well as the index of that pixel (y*w + x), or -1 if there's none.
+func euclideanTransform(w, h int, feature func(x, y int) bool) ([]float64, []int) {
+	// Felzenszwalb & Huttenlocher (2012): the 2D transform is a 1D transform
+	// on the columns followed by a 1D transform on the rows of the result.
+	// See http://cs.brown.edu/people/pfelzens/papers/dt-final.pdf
+	d := make([]float64, w*h)
+	// row of the nearest feature in the column after the first pass
+	rows := make([]int, w*h)
+	nearest := make([]int, w*h)
+
+	n := w
+	if h > n {
+		n = h
+	}
+	f := make([]float64, n)
+	out := make([]float64, n)
+	arg := make([]int, n)
+	v := make([]int, n)
+	z := make([]float64, n+1)
+
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			f[y] = infiniteDistance
+			if feature(x, y) {
+				f[y] = 0
+			}
+		}
+		distanceTransform1D(f[:h], out, arg, v, z)
+		for y := 0; y < h; y++ {
+			d[y*w+x] = out[y]
+			rows[y*w+x] = arg[y]
+		}
+	}
+
+	for y := 0; y < h; y++ {
+		copy(f, d[y*w:(y+1)*w])
+		distanceTransform1D(f[:w], out, arg, v, z)
+		for x := 0; x < w; x++ {
+			i := y*w + x
+			d[i] = out[x]
+			nearest[i] = -1
+			// Without any feature, distances are approximations of
+			// infiniteDistance.
+			if out[x] < infiniteDistance/2 {
+				nearest[i] = rows[y*w+arg[x]]*w + arg[x]
+			}
+		}
+	}
+
+	return d, nearest
+}
+
+// distanceTransform1D computes the lower envelope of the parabolas rooted at
+// each sample of f and writes it in d, along with the index of the parabola
+// each value comes from in arg. v and z are buffers of at least len(f) and
+// len(f)+1 elements.
+func distanceTransform1D(f, d []float64, arg, v []int, z []float64) {
+	n := len(f)
+	if n == 0 {
+		return
+	}
+
+	// intersection of the parabolas rooted at q and p
+	intersection := func(q, p int) float64 {
+		return ((f[q] + float64(q*q)) - (f[p] + float64(p*p))) / float64(2*q-2*p)
+	}
+
+	k := 0
+	v[0] = 0
+	z[0] = -infiniteDistance
+	z[1] = infiniteDistance
+
+	for q := 1; q < n; q++ {
+		s := intersection(q, v[k])
+		for s <= z[k] {
+			k--
+			s = intersection(q, v[k])
+		}
+		k++
+		v[k] = q
+		z[k] = s
+		z[k+1] = infiniteDistance
+	}
+
+	k = 0
+	for q := 0; q < n; q++ {
+		for z[k+1] < float64(q) {
+			k++
+		}
+		d[q] = float64((q-v[k])*(q-v[k])) + f[v[k]]
+		arg[q] = v[k]
+	}
+}
+
+// chamferTransform returns the chamfer distance from each pixel of a w×h grid
+// to the closest one for which feature returns true, and the index of that
+// pixel like euclideanTransform. Steps between 4-neighbours cost ortho and
+// between diagonal neighbours diag; they're not allowed if diag is 0.
+// Distances are divided by ortho.
+func chamferTransform(w, h int, feature func(x, y int) bool, ortho, diag float64) ([]float64, []int) {
+	// Borgefors, "Distance transformations in digital images" (1986): the
+	// distances are propagated in two passes, from the top-left corner then
+	// from the bottom-right one.
+	d := make([]float64, w*h)
+	nearest := make([]int, w*h)
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := y*w + x
+			d[i] = math.Inf(1)
+			nearest[i] = -1
+			if feature(x, y) {
+				d[i] = 0
+				nearest[i] = i
+			}
+		}
+	}
+
+	type step struct {
+		offset
+		cost float64
+	}
+
+	// neighbours already visited in the forward pass
+	forward := []step{{west, ortho}, {north, ortho}}
+	if diag > 0 {
+		forward = append(forward, step{northwest, diag}, step{northeast, diag})
+	}
+	backward := make([]step, len(forward))
+	for i, s := range forward {
+		backward[i] = step{s.reverse(), s.cost}
+	}
+
+	relax := func(x, y int, steps []step) {
+		i := y*w + x
+		for _, s := range steps {
+			nx, ny := s.apply(x, y)
+			if nx < 0 || ny < 0 || nx >= w || ny >= h {
+				continue
+			}
+			j := ny*w + nx
+			if d[j]+s.cost < d[i] {
+				d[i] = d[j] + s.cost
+				nearest[i] = nearest[j]
+			}
+		}
+	}
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			relax(x, y, forward)
+		}
+	}
+	for y := h - 1; y >= 0; y-- {
+		for x := w - 1; x >= 0; x-- {
+			relax(x, y, backward)
+		}
+	}
+
+	for i := range d {
+		d[i] /= ortho
+	}
+	return d, nearest
+}
@@ -0,0 +1,76 @@
+package leonard
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+func TestDistanceTransform(t *testing.T) {
+	b := NewEmptyBinaryImage(7, 7)
+	b.Set(3, 3, true)
+
+	for _, tt := range []struct {
+		metric DistanceMetric
+		corner float64
+		edge   float64
+	}{
+		{EuclideanDistance, math.Sqrt(18), 3},
+		{ChamferDistance, 4, 3},
+		{CityBlockDistance, 6, 3},
+	} {
+		d := b.DistanceTransform(tt.metric)
+		if got := d.FloatAt(3, 3, 0); got != 0 {
+			t.Errorf("metric %d: got %f on the feature, want 0", tt.metric, got)
+		}
+		if got := d.FloatAt(0, 0, 0); math.Abs(float64(got)-tt.corner) > 1e-5 {
+			t.Errorf("metric %d: got %f in the corner, want %f", tt.metric, got, tt.corner)
+		}
+		if got := d.FloatAt(3, 0, 0); math.Abs(float64(got)-tt.edge) > 1e-5 {
+			t.Errorf("metric %d: got %f on the edge, want %f", tt.metric, got, tt.edge)
+		}
+	}
+}
+
+func TestNearestFeatures(t *testing.T) {
+	b := newBinaryImage(
+		"#.......",
+		"........",
+		"........",
+		".......#",
+	)
+
+	for _, metric := range []DistanceMetric{EuclideanDistance, ChamferDistance, CityBlockDistance} {
+		d, nearest := b.NearestFeatures(metric)
+		for _, tt := range []struct {
+			p, want image.Point
+		}{
+			{image.Pt(1, 1), image.Pt(0, 0)},
+			{image.Pt(6, 2), image.Pt(7, 3)},
+			{image.Pt(7, 3), image.Pt(7, 3)},
+		} {
+			if got := nearest[d.PixOffset(tt.p.X, tt.p.Y)]; got != tt.want {
+				t.Errorf("metric %d: got %v for %v, want %v", metric, got, tt.p, tt.want)
+			}
+		}
+	}
+}
+
+func TestDistanceTransformWithoutFeatures(t *testing.T) {
+	d, nearest := NewEmptyBinaryImage(3, 4).NearestFeatures(EuclideanDistance)
+
+	for i, v := range d.Pix {
+		if !math.IsInf(float64(v), 1) {
+			t.Fatalf("got a distance of %f, want +Inf", v)
+		}
+		if nearest[i] != (image.Point{-1, -1}) {
+			t.Fatalf("got a nearest feature %v, want (-1, -1)", nearest[i])
+		}
+	}
+}
+
+func TestDistanceTransformEmptyImage(t *testing.T) {
+	if d := NewEmptyBinaryImage(0, 0).DistanceTransform(ChamferDistance); len(d.Pix) != 0 {
+		t.Errorf("got %d distances, want none", len(d.Pix))
+	}
+}
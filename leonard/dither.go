@@ -0,0 +1,259 @@
+package leonard
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// DitherAlgorithm is an algorithm that reduces the number of colors of an
+// image while keeping its tones, by spreading the difference between the
+// original colors and the ones available on the neighbouring pixels.
+type DitherAlgorithm int
+
+const (
+	// FloydSteinberg diffuses the error of each pixel on its 4 next
+	// neighbours.
+	FloydSteinberg DitherAlgorithm = iota
+	// Atkinson diffuses only 3/4 of the error, on 6 neighbours. It gives more
+	// contrasted images but loses details in the very dark and bright areas.
+	Atkinson
+	// JarvisJudiceNinke diffuses the error on 12 neighbours. It's slower than
+	// FloydSteinberg but gives smoother images.
+	JarvisJudiceNinke
+	// Stucki is a variant of JarvisJudiceNinke with different weights that
+	// gives sharper images.
+	Stucki
+	// Bayer2 is an ordered dithering with a 2x2 Bayer matrix. Ordered
+	// dithering gives regular patterns and doesn't depend on the neighbouring
+	// pixels, so it doesn't flicker in animations.
+	Bayer2
+	// Bayer4 is an ordered dithering with a 4x4 Bayer matrix
+	Bayer4
+	// Bayer8 is an ordered dithering with an 8x8 Bayer matrix
+	Bayer8
+)
+
+// diffusionWeight is the fraction of the error of a pixel diffused on the
+// pixel at (dx, dy) from it.
+type diffusionWeight struct {
+	dx, dy int
+	weight float32
+}
+
+// diffusionKernel returns the weights of an error diffusion algorithm, or nil
+// if it's an ordered dithering.
+func (alg DitherAlgorithm) diffusionKernel() []diffusionWeight {
+	kernel := func(divisor float32, weights ...diffusionWeight) []diffusionWeight {
+		for i := range weights {
+			weights[i].weight /= divisor
+		}
+		return weights
+	}
+
+	switch alg {
+	case FloydSteinberg:
+		return kernel(16,
+			diffusionWeight{1, 0, 7},
+			diffusionWeight{-1, 1, 3}, diffusionWeight{0, 1, 5}, diffusionWeight{1, 1, 1},
+		)
+	case Atkinson:
+		return kernel(8,
+			diffusionWeight{1, 0, 1}, diffusionWeight{2, 0, 1},
+			diffusionWeight{-1, 1, 1}, diffusionWeight{0, 1, 1}, diffusionWeight{1, 1, 1},
+			diffusionWeight{0, 2, 1},
+		)
+	case JarvisJudiceNinke:
+		return kernel(48,
+			diffusionWeight{1, 0, 7}, diffusionWeight{2, 0, 5},
+			diffusionWeight{-2, 1, 3}, diffusionWeight{-1, 1, 5}, diffusionWeight{0, 1, 7}, diffusionWeight{1, 1, 5}, diffusionWeight{2, 1, 3},
+			diffusionWeight{-2, 2, 1}, diffusionWeight{-1, 2, 3}, diffusionWeight{0, 2, 5}, diffusionWeight{1, 2, 3}, diffusionWeight{2, 2, 1},
+		)
+	case Stucki:
+		return kernel(42,
+			diffusionWeight{1, 0, 8}, diffusionWeight{2, 0, 4},
+			diffusionWeight{-2, 1, 2}, diffusionWeight{-1, 1, 4}, diffusionWeight{0, 1, 8}, diffusionWeight{1, 1, 4}, diffusionWeight{2, 1, 2},
+			diffusionWeight{-2, 2, 1}, diffusionWeight{-1, 2, 2}, diffusionWeight{0, 2, 4}, diffusionWeight{1, 2, 2}, diffusionWeight{2, 2, 1},
+		)
+	}
+	return nil
+}
+
+// bayerMatrix returns the thresholds of an ordered dithering algorithm, between
+// 0 and 1, or nil if it's an error diffusion.
+func (alg DitherAlgorithm) bayerMatrix() [][]float32 {
+	var size int
+	switch alg {
+	case Bayer2:
+		size = 2
+	case Bayer4:
+		size = 4
+	case Bayer8:
+		size = 8
+	default:
+		return nil
+	}
+
+	// Each matrix is built from the previous one:
+	//   M(2n) = | 4M(n)   4M(n)+2 |
+	//           | 4M(n)+3 4M(n)+1 |
+	m := [][]int{{0}}
+	for n := 1; n < size; n *= 2 {
+		next := make([][]int, 2*n)
+		for y := range next {
+			next[y] = make([]int, 2*n)
+			for x := range next[y] {
+				next[y][x] = 4*m[y%n][x%n] + [2][2]int{{0, 2}, {3, 1}}[y/n][x/n]
+			}
+		}
+		m = next
+	}
+
+	thresholds := make([][]float32, size)
+	for y := range m {
+		thresholds[y] = make([]float32, size)
+		for x, v := range m[y] {
+			thresholds[y][x] = (float32(v) + 0.5) / float32(size*size)
+		}
+	}
+	return thresholds
+}
+
+// dither quantizes the samples of f, which is modified. nearest returns the
+// index of the available color the closest to the given samples, and the
+// samples of that color. spread is the difference between two available
+// colors, used by ordered dithering.
+//
+// It returns the index of the color of each pixel, row by row.
+func (alg DitherAlgorithm) dither(f *FloatImage, spread float32, nearest func([]float32) (int, []float32)) []int {
+	bounds := f.Rect
+	w, h := bounds.Dx(), bounds.Dy()
+	channels := f.Channels
+	indices := make([]int, w*h)
+
+	if thresholds := alg.bayerMatrix(); thresholds != nil {
+		n := len(thresholds)
+		samples := make([]float32, channels)
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				i := y*f.Stride + x*channels
+				offset := spread * (thresholds[y%n][x%n] - 0.5)
+				for c := range samples {
+					samples[c] = f.Pix[i+c]
+					// the alpha channel isn't dithered
+					if c < 3 {
+						samples[c] += offset
+					}
+				}
+				indices[y*w+x], _ = nearest(samples)
+			}
+		}
+		return indices
+	}
+
+	kernel := alg.diffusionKernel()
+	if kernel == nil {
+		panic("Invalid dithering algorithm")
+	}
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := y*f.Stride + x*channels
+			samples := f.Pix[i : i+channels]
+
+			index, closest := nearest(samples)
+			indices[y*w+x] = index
+
+			for c, v := range samples {
+				err := v - closest[c]
+				if err == 0 {
+					continue
+				}
+				for _, k := range kernel {
+					nx, ny := x+k.dx, y+k.dy
+					if nx < 0 || nx >= w || ny >= h {
+						continue
+					}
+					f.Pix[ny*f.Stride+nx*channels+c] += err * k.weight
+				}
+			}
+		}
+	}
+
+	return indices
+}
+
+// Dither converts an image to black & white with the given algorithm. Unlike
+// NewBinaryImage, the tones of the image are kept: areas are rendered with
+// more or less white pixels depending on their luminance.
+func Dither(img image.Image, alg DitherAlgorithm) *BinaryImage {
+	bounds := img.Bounds()
+	f := NewFloatImageFrom(img, 1)
+
+	bw := [][]float32{{0}, {1}}
+	indices := alg.dither(f, 1, func(v []float32) (int, []float32) {
+		if v[0] >= 0.5 {
+			return 1, bw[1]
+		}
+		return 0, bw[0]
+	})
+
+	b := NewEmptyBinaryImage(bounds.Max.Y, bounds.Max.X)
+	w := bounds.Dx()
+	for i, index := range indices {
+		if index == 1 {
+			b.Set(bounds.Min.X+i%w, bounds.Min.Y+i/w, true)
+		}
+	}
+	return b
+}
+
+// DitherPaletted converts an image to a paletted image with the given palette
+// and algorithm. Colors are compared with their non-premultiplied RGBA
+// channels.
+func DitherPaletted(img image.Image, p color.Palette, alg DitherAlgorithm) *image.Paletted {
+	if len(p) == 0 || len(p) > 256 {
+		panic("DitherPaletted: the palette must have between 1 and 256 colors")
+	}
+
+	bounds := img.Bounds()
+	f := NewFloatImageFrom(img, 4)
+
+	colors := make([][]float32, len(p))
+	for i, c := range p {
+		nc := color.NRGBA64Model.Convert(c).(color.NRGBA64)
+		colors[i] = []float32{
+			float32(nc.R) / 0xFFFF,
+			float32(nc.G) / 0xFFFF,
+			float32(nc.B) / 0xFFFF,
+			float32(nc.A) / 0xFFFF,
+		}
+	}
+
+	// Assuming the colors of the palette are evenly distributed, there are
+	// about len(p)^(1/3) levels on each channel.
+	spread := float32(1)
+	if levels := math.Cbrt(float64(len(p))); levels > 2 {
+		spread = float32(1 / (levels - 1))
+	}
+
+	indices := alg.dither(f, spread, func(v []float32) (int, []float32) {
+		best, bestDistance := 0, float32(math.Inf(1))
+		for i, c := range colors {
+			d := float32(0)
+			for k := range c {
+				d += (v[k] - c[k]) * (v[k] - c[k])
+			}
+			if d < bestDistance {
+				best, bestDistance = i, d
+			}
+		}
+		return best, colors[best]
+	})
+
+	paletted := image.NewPaletted(bounds, p)
+	for i, index := range indices {
+		paletted.Pix[i] = uint8(index)
+	}
+	return paletted
+}
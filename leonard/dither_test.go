@@ -0,0 +1,96 @@
+package leonard
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+var ditherAlgorithms = []DitherAlgorithm{
+	FloydSteinberg, Atkinson, JarvisJudiceNinke, Stucki, Bayer2, Bayer4, Bayer8,
+}
+
+func TestDitherKeepsTones(t *testing.T) {
+	for _, alg := range ditherAlgorithms {
+		for _, level := range []uint8{0, 64, 128, 192, 255} {
+			b := Dither(uniformGray(32, 32, level), alg)
+
+			white := float64(len(b.Points())) / (32 * 32)
+			want := float64(level) / 255
+			tolerance := 0.05
+			if alg == Atkinson {
+				// it only diffuses 3/4 of the error
+				tolerance = 0.1
+			}
+			if math.Abs(white-want) > tolerance {
+				t.Errorf("algorithm %d, level %d: got %.2f white pixels, want %.2f", alg, level, white, want)
+			}
+		}
+	}
+}
+
+func TestDitherBayer2Pattern(t *testing.T) {
+	b := Dither(uniformGray(4, 4, 128), Bayer2)
+
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			// the thresholds of the 2x2 matrix are 1/8, 5/8, 7/8 and 3/8
+			want := (x+y)%2 == 1
+			if got := b.Get(x, y); got != want {
+				t.Errorf("got %v at (%d, %d), want %v", got, x, y, want)
+			}
+		}
+	}
+}
+
+func TestDitherPaletted(t *testing.T) {
+	p := color.Palette{color.Black, color.White, color.RGBA{255, 0, 0, 255}}
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for i := range img.Pix {
+		img.Pix[i] = 255
+	}
+	// a red square on a white background
+	for y := 2; y < 6; y++ {
+		for x := 2; x < 6; x++ {
+			img.SetRGBA(x, y, color.RGBA{255, 0, 0, 255})
+		}
+	}
+
+	for _, alg := range ditherAlgorithms {
+		paletted := DitherPaletted(img, p, alg)
+		if paletted.Bounds() != img.Bounds() {
+			t.Fatalf("got bounds %v, want %v", paletted.Bounds(), img.Bounds())
+		}
+		// colors of the palette are kept as is
+		for y := 0; y < 8; y++ {
+			for x := 0; x < 8; x++ {
+				want := 1
+				if x >= 2 && x < 6 && y >= 2 && y < 6 {
+					want = 2
+				}
+				if got := int(paletted.ColorIndexAt(x, y)); got != want {
+					t.Errorf("algorithm %d: got index %d at (%d, %d), want %d", alg, got, x, y, want)
+				}
+			}
+		}
+	}
+}
+
+func TestDitherPalettedInvalidPalette(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic")
+		}
+	}()
+	DitherPaletted(uniformGray(2, 2, 0), color.Palette{}, FloydSteinberg)
+}
+
+func TestDitherInvalidAlgorithm(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic")
+		}
+	}()
+	Dither(uniformGray(2, 2, 0), 42)
+}
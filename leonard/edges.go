@@ -1,77 +1,347 @@
 package leonard
 
 import (
+	"fmt"
 	"image"
-	"image/color"
 	"math"
 )
 
-func gradients(img image.Image, fn func(image.Image, int, int) float64) image.Image {
-	bounds := img.Bounds()
-	grads := image.NewGray16(bounds)
+// GradientField holds the gradients of the luminance of an image. Luminance
+// values are in the 0-1 range; gradients are signed and not normalized.
+type GradientField struct {
+	// Gx and Gy are the horizontal and vertical gradients. Gx is positive
+	// when the luminance increases towards the right and Gy when it increases
+	// towards the bottom.
+	Gx, Gy *FloatImage
+	// Magnitude is sqrt(Gx² + Gy²)
+	Magnitude *FloatImage
+	// Angle is the direction of the gradient in radians, in [-π, π]. 0 points
+	// to the right and π/2 to the bottom.
+	Angle *FloatImage
+
+	opts GradientOptions
+}
 
-	// Skip the borders
-	minX, maxX := bounds.Min.X+1, bounds.Max.X-1
-	minY, maxY := bounds.Min.Y+1, bounds.Max.Y-1
+// GradientOperator is an operator used to compute the gradients of an image
+type GradientOperator int
+
+const (
+	// CentralDifference is the simplest operator: the gradient at a pixel is
+	// the difference between its neighbours. It's very sensitive to noise.
+	CentralDifference GradientOperator = iota
+	// Sobel is the 3x3 Sobel operator
+	Sobel
+	// Sobel5 is the 5x5 Sobel operator. It's less sensitive to noise than the
+	// 3x3 one but blurs the edges more.
+	Sobel5
+	// Scharr is the Scharr operator, a 3x3 operator with a better rotational
+	// symmetry than Sobel's.
+	Scharr
+	// Prewitt is the 3x3 Prewitt operator
+	Prewitt
+	// Roberts is the 2x2 Roberts cross operator
+	Roberts
+)
 
-	maxGrad := 0.0
+// gradientKernel is the square horizontal kernel of an operator; the vertical
+// one is its transpose. The kernels are scaled so that a sharp step from 0 to 1
+// gives a gradient of 1, except Sobel5's which spreads it on more pixels. The
+// 3x3 and 5x5 ones then also give the same gradients on a linear ramp.
+type gradientKernel struct {
+	weights [][]float32
+	// position of the pixel the kernel is applied on
+	center int
+}
 
-	for x := minX; x < maxX; x++ {
-		for y := minY; y < maxY; y++ {
-			g := math.Abs(fn(img, x, y))
-			if g > maxGrad {
-				maxGrad = g
-			}
-			// Storing stuff in a [][]uint16 takes more memory and doesn't save
-			// us time here
-			grads.SetGray16(x, y, color.Gray16{uint16(g)})
+var gradientKernels = map[GradientOperator]gradientKernel{
+	CentralDifference: {[][]float32{
+		{0, 0, 0},
+		{-1, 0, 1},
+		{0, 0, 0},
+	}, 1},
+	Sobel: {scaleKernel([][]float32{
+		{-1, 0, 1},
+		{-2, 0, 2},
+		{-1, 0, 1},
+	}, 4), 1},
+	Sobel5: {scaleKernel([][]float32{
+		{-1, -2, 0, 2, 1},
+		{-4, -8, 0, 8, 4},
+		{-6, -12, 0, 12, 6},
+		{-4, -8, 0, 8, 4},
+		{-1, -2, 0, 2, 1},
+	}, 64), 2},
+	Scharr: {scaleKernel([][]float32{
+		{-3, 0, 3},
+		{-10, 0, 10},
+		{-3, 0, 3},
+	}, 16), 1},
+	Prewitt: {scaleKernel([][]float32{
+		{-1, 0, 1},
+		{-1, 0, 1},
+		{-1, 0, 1},
+	}, 3), 1},
+	// The Roberts cross gives the gradients along the diagonals:
+	//     G1 = I(x+1, y+1) - I(x, y)
+	//     G2 = I(x, y+1) - I(x+1, y)
+	// We rotate them with Gx = (G1 - G2)/2 and Gy = (G1 + G2)/2.
+	Roberts: {scaleKernel([][]float32{
+		{-1, 1},
+		{-1, 1},
+	}, 2), 0},
+}
+
+func scaleKernel(k [][]float32, divisor float32) [][]float32 {
+	for _, row := range k {
+		for i := range row {
+			row[i] /= divisor
 		}
 	}
+	return k
+}
+
+// GradientNormalization defines how gradients are mapped to the 0-1 range
+// when they're converted into images.
+type GradientNormalization int
+
+const (
+	// MaxNormalization divides the gradients by the highest one of the image.
+	// The same edge may then get different values in different images.
+	MaxNormalization GradientNormalization = iota
+	// NoNormalization keeps the absolute values of the gradients, clamped to
+	// 1. A sharp step from black to white gives a gradient of 1 with all
+	// operators but Sobel5, which gives 0.75.
+	NoNormalization
+	// FixedNormalization divides the gradients by GradientOptions.Range and
+	// clamps them to 1. Use it to compare gradients across images.
+	FixedNormalization
+	// PercentileNormalization divides the gradients by the given percentile of
+	// the image and clamps them to 1, so that a few very strong edges don't
+	// darken the others.
+	PercentileNormalization
+)
+
+// GradientOptions are the options used to compute gradients. A nil
+// *GradientOptions means the defaults are used.
+type GradientOptions struct {
+	// The default is CentralDifference
+	Operator GradientOperator
+
+	// The default is MaxNormalization. Whatever the normalization, the
+	// gradients of a flat image are all 0.
+	Normalization GradientNormalization
+	// Range is the gradient mapped to 1 by FixedNormalization
+	Range float64
+	// Percentile is the percentile, between 0 and 1, of the gradients that's
+	// mapped to 1 by PercentileNormalization, e.g. 0.99. If that gradient is 0,
+	// e.g. because the image has very few edges, the highest one is used
+	// instead.
+	Percentile float64
+}
+
+// normalize returns the absolute values of some gradients mapped to the 0-1
+// range according to the options.
+func (o *GradientOptions) normalize(f *FloatImage) *FloatImage {
+	n := f.Clone()
+	for i, v := range n.Pix {
+		n.Pix[i] = float32(math.Abs(float64(v)))
+	}
 
-	// adjust based on the max value
-	maxGrad /= 0xFFFF
+	_, max := n.MinMax()
 
-	for x := minX; x < maxX; x++ {
-		for y := minY; y < maxY; y++ {
-			g := grads.Gray16At(x, y)
-			g.Y = uint16(float64(g.Y) / maxGrad)
-			grads.SetGray16(x, y, g)
+	var scale float32
+
+	switch o.Normalization {
+	case NoNormalization:
+		scale = 1
+	case FixedNormalization:
+		scale = float32(o.Range)
+	case PercentileNormalization:
+		scale = n.Percentile(o.Percentile)
+		if scale == 0 {
+			scale = max
 		}
+	default:
+		scale = max
+	}
+
+	if max == 0 {
+		// Flat image: there's nothing to scale
+		return n
 	}
 
-	return grads
+	for i, v := range n.Pix {
+		n.Pix[i] = v / scale
+	}
+	return n.Normalize(ClampPolicy)
 }
 
-func horizontalGradient(img image.Image, x, y int) float64 {
-	return float64(luminance(img.At(x+1, y)) - luminance(img.At(x-1, y)))
+// validate returns an error if the options are invalid
+func (o *GradientOptions) validate() error {
+	if _, ok := gradientKernels[o.Operator]; !ok {
+		return fmt.Errorf("invalid gradient operator: %d", o.Operator)
+	}
+
+	switch o.Normalization {
+	case MaxNormalization, NoNormalization:
+	case FixedNormalization:
+		if !(o.Range > 0) {
+			return fmt.Errorf("invalid gradient range: %g", o.Range)
+		}
+	case PercentileNormalization:
+		if !(o.Percentile >= 0 && o.Percentile <= 1) {
+			return fmt.Errorf("invalid gradient percentile: %g", o.Percentile)
+		}
+	default:
+		return fmt.Errorf("invalid gradient normalization: %d", o.Normalization)
+	}
+	return nil
 }
 
-func verticalGradient(img image.Image, x, y int) float64 {
-	return float64(luminance(img.At(x, y+1)) - luminance(img.At(x, y-1)))
+// NewGradientField computes the gradients of an image with the default
+// options. Gradients on the borders of the image, where the operator can't be
+// applied, are 0.
+func NewGradientField(img image.Image) *GradientField {
+	// the default options are valid
+	g, _ := NewGradientFieldWithOptions(img, nil)
+	return g
+}
+
+// NewGradientFieldWithOptions is like NewGradientField with the given options.
+// An error is returned if they're invalid.
+func NewGradientFieldWithOptions(img image.Image, opts *GradientOptions) (*GradientField, error) {
+	// Read e.g. http://www.cse.psu.edu/~rtc12/CSE486/lecture02.pdf
+	// Also: https://www.cs.umd.edu/~djacobs/CMSC426/ImageGradients.pdf
+	//       https://en.wikipedia.org/wiki/Image_gradient
+	//       https://en.wikipedia.org/wiki/Sobel_operator
+	//       https://en.wikipedia.org/wiki/Prewitt_operator
+	//       https://en.wikipedia.org/wiki/Roberts_cross
+	if opts == nil {
+		opts = &GradientOptions{}
+	}
+
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	kernel := gradientKernels[opts.Operator]
+	lum := NewFloatImageFrom(img, 1)
+
+	bounds := img.Bounds()
+	g := &GradientField{
+		Gx:        NewFloatImage(bounds, 1),
+		Gy:        NewFloatImage(bounds, 1),
+		Magnitude: NewFloatImage(bounds, 1),
+		Angle:     NewFloatImage(bounds, 1),
+		opts:      *opts,
+	}
+
+	size := len(kernel.weights)
+
+	// Skip the borders
+	minX, maxX := bounds.Min.X+kernel.center, bounds.Max.X-(size-kernel.center-1)
+	minY, maxY := bounds.Min.Y+kernel.center, bounds.Max.Y-(size-kernel.center-1)
+
+	for y := minY; y < maxY; y++ {
+		for x := minX; x < maxX; x++ {
+			var gx, gy float32
+
+			for ky, row := range kernel.weights {
+				for kx, w := range row {
+					if w == 0 {
+						continue
+					}
+					// horizontal kernel
+					gx += w * lum.FloatAt(x+kx-kernel.center, y+ky-kernel.center, 0)
+					// vertical kernel, i.e. the transpose of the horizontal one
+					gy += w * lum.FloatAt(x+ky-kernel.center, y+kx-kernel.center, 0)
+				}
+			}
+
+			i := g.Gx.PixOffset(x, y)
+			g.Gx.Pix[i] = gx
+			g.Gy.Pix[i] = gy
+			g.Magnitude.Pix[i] = float32(math.Hypot(float64(gx), float64(gy)))
+			g.Angle.Pix[i] = float32(math.Atan2(float64(gy), float64(gx)))
+		}
+	}
+
+	return g, nil
+}
+
+// DirectionImage returns an image that represents the direction of the
+// gradients by the hue of each pixel and their magnitude by its value. The
+// magnitude is normalized as set in the options of the field.
+func (g *GradientField) DirectionImage() image.Image {
+	magnitude := g.opts.normalize(g.Magnitude)
+
+	bounds := g.Angle.Rect
+	img := image.NewRGBA64(bounds)
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			i := g.Angle.PixOffset(x, y)
+			// [-π, π] -> [0, 360]
+			hue := (float64(g.Angle.Pix[i]) + math.Pi) * 180 / math.Pi
+			img.Set(x, y, hsvToRGB(hue, 1, float64(magnitude.Pix[i])))
+		}
+	}
+
+	return img
 }
 
 // HorizontalGradients returns an image that represents the magnitude of the
-// horizontal gradients
+// horizontal gradients, computed with the default options.
 func HorizontalGradients(img image.Image) image.Image {
-	return gradients(img, horizontalGradient)
+	// the default options are valid
+	g, _ := HorizontalGradientsWithOptions(img, nil)
+	return g
+}
+
+// HorizontalGradientsWithOptions is like HorizontalGradients with the given
+// options. An error is returned if they're invalid.
+func HorizontalGradientsWithOptions(img image.Image, opts *GradientOptions) (image.Image, error) {
+	g, err := NewGradientFieldWithOptions(img, opts)
+	if err != nil {
+		return nil, err
+	}
+	return g.opts.normalize(g.Gx).Image(ClampPolicy), nil
 }
 
 // VerticalGradients returns an image that represents the magnitude of the
-// vertical gradients
+// vertical gradients, computed with the default options.
 func VerticalGradients(img image.Image) image.Image {
-	return gradients(img, verticalGradient)
+	// the default options are valid
+	g, _ := VerticalGradientsWithOptions(img, nil)
+	return g
 }
 
-// Gradients returns an image that represents the magnitude of gradients
+// VerticalGradientsWithOptions is like VerticalGradients with the given
+// options. An error is returned if they're invalid.
+func VerticalGradientsWithOptions(img image.Image, opts *GradientOptions) (image.Image, error) {
+	g, err := NewGradientFieldWithOptions(img, opts)
+	if err != nil {
+		return nil, err
+	}
+	return g.opts.normalize(g.Gy).Image(ClampPolicy), nil
+}
+
+// Gradients returns an image that represents the magnitude of gradients,
+// computed with the default options.
 func Gradients(img image.Image) image.Image {
-	return gradients(img, func(img image.Image, x, y int) float64 {
-		// Read e.g. http://www.cse.psu.edu/~rtc12/CSE486/lecture02.pdf
-		// Also: https://www.cs.umd.edu/~djacobs/CMSC426/ImageGradients.pdf
-		//       https://en.wikipedia.org/wiki/Image_gradient
-		h := horizontalGradient(img, x, y)
-		v := verticalGradient(img, x, y)
-		return math.Sqrt(h*h + v*v)
-	})
+	// the default options are valid
+	g, _ := GradientsWithOptions(img, nil)
+	return g
+}
+
+// GradientsWithOptions is like Gradients with the given options. An error is
+// returned if they're invalid.
+func GradientsWithOptions(img image.Image, opts *GradientOptions) (image.Image, error) {
+	g, err := NewGradientFieldWithOptions(img, opts)
+	if err != nil {
+		return nil, err
+	}
+	return g.opts.normalize(g.Magnitude).Image(ClampPolicy), nil
 }
 
 func (b *BinaryImage) thinEdgesIteration(odd bool) (*BinaryImage, bool) {
@@ -185,7 +455,7 @@ func (b *BinaryImage) ThinEdges() *BinaryImage {
 	// https://dl.acm.org/citation.cfm?id=358023
 	// http://article.sciencepublishinggroup.com/pdf/10.11648.j.ajsea.20130201.11.pdf
 
-	// TODO check http://www.uel.br/pessoal/josealexandre/stuff/thinning/ftp/zhang-wang.pdf
+	// See Thin for other algorithms.
 
 	// There's also this algorithm but it's really slow:
 	// https://users.fmrib.ox.ac.uk/~steve/susan/thinning/node2.html
@@ -0,0 +1,200 @@
+package leonard
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+// stepImage returns a w×h image that's black on its left half and white on
+// its right one.
+func stepImage(w, h int) *FloatImage {
+	return NewFloatImageFrom(newGrayImage(w, h, func(x, y int) uint8 {
+		if x < w/2 {
+			return 0
+		}
+		return 255
+	}), 1)
+}
+
+func TestGradientsOfAStep(t *testing.T) {
+	img := stepImage(12, 12)
+
+	for op, want := range map[GradientOperator]float64{
+		CentralDifference: 1,
+		Sobel:             1,
+		Sobel5:            0.75,
+		Scharr:            1,
+		Prewitt:           1,
+		Roberts:           1,
+	} {
+		g, err := NewGradientFieldWithOptions(img, &GradientOptions{Operator: op})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, max := g.Gx.MinMax()
+		if math.Abs(float64(max)-want) > 1e-6 {
+			t.Errorf("operator %d: got a maximal gradient of %f, want %f", op, max, want)
+		}
+		if min, max := g.Gy.MinMax(); min != 0 || max != 0 {
+			t.Errorf("operator %d: got vertical gradients in [%f, %f], want 0", op, min, max)
+		}
+	}
+}
+
+func TestGradientsOfARamp(t *testing.T) {
+	img := NewFloatImageFrom(newGrayImage(12, 12, func(x, y int) uint8 {
+		return uint8(10 * y)
+	}), 1)
+	slope := float32(10) / 255
+
+	for _, op := range []GradientOperator{CentralDifference, Sobel, Sobel5, Scharr, Prewitt} {
+		g, _ := NewGradientFieldWithOptions(img, &GradientOptions{Operator: op})
+		if got := g.Gy.FloatAt(6, 6, 0); math.Abs(float64(got-2*slope)) > 1e-5 {
+			t.Errorf("operator %d: got %f, want %f", op, got, 2*slope)
+		}
+		if got := g.Angle.FloatAt(6, 6, 0); math.Abs(float64(got)-math.Pi/2) > 1e-5 {
+			t.Errorf("operator %d: got an angle of %f, want π/2", op, got)
+		}
+	}
+}
+
+func TestGradientsDefaultOptions(t *testing.T) {
+	img := stepImage(8, 8)
+
+	want, err := GradientsWithOptions(img, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := Gradients(img)
+
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			if gray16At(got, x, y) != gray16At(want, x, y) {
+				t.Fatalf("Gradients and GradientsWithOptions differ at (%d, %d)", x, y)
+			}
+		}
+	}
+
+	if gray16At(HorizontalGradients(img), 4, 4) != 0xFFFF {
+		t.Error("expected a horizontal gradient on the step")
+	}
+	if gray16At(VerticalGradients(img), 4, 4) != 0 {
+		t.Error("expected no vertical gradient on the step")
+	}
+}
+
+func TestGradientsFlatImage(t *testing.T) {
+	img := uniformGray(8, 8, 100)
+
+	for _, n := range []GradientNormalization{MaxNormalization, NoNormalization, PercentileNormalization} {
+		g, err := GradientsWithOptions(img, &GradientOptions{Normalization: n, Percentile: 0.5})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := gray16At(g, 4, 4); v != 0 {
+			t.Errorf("normalization %d: got %d, want 0", n, v)
+		}
+	}
+}
+
+func TestGradientsInvalidOperator(t *testing.T) {
+	if _, err := NewGradientFieldWithOptions(uniformGray(4, 4, 0), &GradientOptions{Operator: 42}); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestGradientsInvalidNormalization(t *testing.T) {
+	img := uniformGray(4, 4, 0)
+
+	for _, opts := range []GradientOptions{
+		{Normalization: FixedNormalization},
+		{Normalization: FixedNormalization, Range: -1},
+		{Normalization: FixedNormalization, Range: math.NaN()},
+		{Normalization: PercentileNormalization, Percentile: -0.1},
+		{Normalization: PercentileNormalization, Percentile: 1.5},
+		{Normalization: 42},
+	} {
+		opts := opts
+		if _, err := GradientsWithOptions(img, &opts); err == nil {
+			t.Errorf("%+v: expected an error", opts)
+		}
+	}
+}
+
+func TestGradientsNormalization(t *testing.T) {
+	img := stepImage(12, 12)
+
+	for _, tt := range []struct {
+		opts GradientOptions
+		want uint16
+	}{
+		{GradientOptions{Normalization: NoNormalization}, 0xFFFF},
+		{GradientOptions{Normalization: NoNormalization, Operator: Roberts}, 0xFFFF},
+		{GradientOptions{Normalization: NoNormalization, Operator: Sobel5}, 0xBFFF},
+		{GradientOptions{Normalization: FixedNormalization, Range: 2}, 0x7FFF},
+		{GradientOptions{Normalization: PercentileNormalization, Percentile: 0.99}, 0xFFFF},
+	} {
+		tt := tt
+		g, err := HorizontalGradientsWithOptions(img, &tt.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var max uint16
+		for y := 0; y < 12; y++ {
+			for x := 0; x < 12; x++ {
+				if v := gray16At(g, x, y); v > max {
+					max = v
+				}
+			}
+		}
+		if d := int(max) - int(tt.want); d < -1 || d > 1 {
+			t.Errorf("%+v: got %#x, want %#x", tt.opts, max, tt.want)
+		}
+	}
+}
+
+func TestGradientDirection(t *testing.T) {
+	// the luminance increases towards the right, then towards the bottom
+	horizontal := stepImage(12, 12)
+	vertical := NewFloatImageFrom(newGrayImage(12, 12, func(x, y int) uint8 {
+		if y < 6 {
+			return 0
+		}
+		return 255
+	}), 1)
+
+	for _, tt := range []struct {
+		name  string
+		img   image.Image
+		angle float64
+		// color of the direction image on the step
+		want color.RGBA64
+	}{
+		{"horizontal step", horizontal, 0, color.RGBA64{0, 0xFFFF, 0xFFFF, 0xFFFF}},
+		{"vertical step", vertical, math.Pi / 2, color.RGBA64{0x8000, 0, 0xFFFF, 0xFFFF}},
+	} {
+		for _, op := range []GradientOperator{CentralDifference, Sobel, Roberts} {
+			g, _ := NewGradientFieldWithOptions(tt.img, &GradientOptions{Operator: op})
+			// Roberts' gradient is between the pixels
+			x, y := 6, 6
+			if op == Roberts {
+				x, y = 5, 5
+			}
+			if got := g.Angle.FloatAt(x, y, 0); math.Abs(float64(got)-tt.angle) > 1e-6 {
+				t.Errorf("%s, operator %d: got an angle of %f, want %f", tt.name, op, got, tt.angle)
+			}
+		}
+
+		d := NewGradientField(tt.img).DirectionImage()
+		if got := color.RGBA64Model.Convert(d.At(6, 6)).(color.RGBA64); absDiff16(got.R, tt.want.R) > 1 || got.G != tt.want.G || got.B != tt.want.B {
+			t.Errorf("%s: got %v on the step, want %v", tt.name, got, tt.want)
+		}
+		if got := color.GrayModel.Convert(d.At(1, 1)).(color.Gray).Y; got != 0 {
+			t.Errorf("%s: got %d on a flat area, want black", tt.name, got)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package leonard
+
+import (
+	"image"
+	"math"
+	"math/bits"
+	"math/rand"
+	"sort"
+)
+
+const (
+	// briefPatchRadius is the radius of the patch around a keypoint that's
+	// looked at to compute its descriptor and orientation.
+	briefPatchRadius = 15
+
+	defaultDescriptorSigma = 2.0
+	defaultMatchRatio      = 0.8
+)
+
+// Descriptor is a binary descriptor of the patch around a keypoint. Each of its
+// 256 bits compares the luminance of two pixels of the patch.
+type Descriptor [4]uint64
+
+// Distance returns the Hamming distance between two descriptors, i.e. the
+// number of bits that differ, between 0 and 256.
+func (d Descriptor) Distance(other Descriptor) int {
+	n := 0
+	for i := range d {
+		n += bits.OnesCount64(d[i] ^ other[i])
+	}
+	return n
+}
+
+// briefPattern holds the pairs of pixels compared by the descriptors, relative
+// to the keypoint: bit i compares briefPattern[i][0] and briefPattern[i][1].
+var briefPattern = newBriefPattern()
+
+// newBriefPattern returns random pairs of points of the patch, drawn from an
+// isotropic gaussian distribution. Points are kept in the disc of the patch so
+// that they stay in it when rotated. The generator is seeded so that the
+// descriptors don't change between runs.
+func newBriefPattern() [256][2]image.Point {
+	// Calonder et al., "BRIEF: Binary Robust Independent Elementary
+	// Features" (2010), sampling strategy G II.
+	r := rand.New(rand.NewSource(1))
+	sigma := float64(2*briefPatchRadius+1) / 5
+
+	point := func() image.Point {
+		for {
+			x := int(math.Round(r.NormFloat64() * sigma))
+			y := int(math.Round(r.NormFloat64() * sigma))
+			if x*x+y*y <= briefPatchRadius*briefPatchRadius {
+				return image.Point{x, y}
+			}
+		}
+	}
+
+	var pattern [256][2]image.Point
+	for i := range pattern {
+		pattern[i] = [2]image.Point{point(), point()}
+	}
+	return pattern
+}
+
+// Feature is a keypoint along with its descriptor
+type Feature struct {
+	Keypoint
+	Descriptor Descriptor
+}
+
+// DescriptorOptions are the options of Describe. A nil *DescriptorOptions
+// means the defaults are used.
+type DescriptorOptions struct {
+	// Sigma is the standard deviation of the gaussian filter applied on the
+	// image before computing the descriptors, which are very sensitive to
+	// noise. The default is 2.
+	Sigma float64
+	// Upright skips the orientation of the keypoints, like the original
+	// BRIEF. Descriptors are then faster to compute but change when the image
+	// is rotated.
+	Upright bool
+}
+
+// Describe computes the descriptors of some keypoints of an image, e.g. found
+// by Corners. Keypoints too close to the borders of the image to be described
+// are dropped; the others keep their order.
+//
+// Unless opts.Upright is set the keypoints are oriented first, as in ORB, so
+// that the descriptors of a rotated image match those of the original one.
+func Describe(img image.Image, keypoints []Keypoint, opts *DescriptorOptions) []Feature {
+	// Rublee et al., "ORB: an efficient alternative to SIFT or SURF" (2011)
+	if opts == nil {
+		opts = &DescriptorOptions{}
+	}
+	sigma := opts.Sigma
+	if sigma <= 0 {
+		sigma = defaultDescriptorSigma
+	}
+
+	lum := NewFloatImageFrom(GaussianFilter(img, sigma), 1)
+
+	// Rotated points of the pattern can land one pixel away from the patch
+	// because of the rounding.
+	inner := lum.Rect.Inset(briefPatchRadius + 1)
+
+	var features []Feature
+	for _, k := range keypoints {
+		if !(image.Point{k.X, k.Y}.In(inner)) {
+			continue
+		}
+
+		if !opts.Upright {
+			k.Angle = patchOrientation(lum, k.X, k.Y)
+		}
+
+		f := Feature{Keypoint: k}
+		sin, cos := math.Sincos(k.Angle)
+		rotate := func(p image.Point) (int, int) {
+			x, y := float64(p.X), float64(p.Y)
+			return k.X + int(math.Round(cos*x-sin*y)), k.Y + int(math.Round(sin*x+cos*y))
+		}
+
+		for i, pair := range briefPattern {
+			x1, y1 := rotate(pair[0])
+			x2, y2 := rotate(pair[1])
+			if lum.FloatAt(x1, y1, 0) < lum.FloatAt(x2, y2, 0) {
+				f.Descriptor[i/64] |= 1 << uint(i%64)
+			}
+		}
+		features = append(features, f)
+	}
+
+	return features
+}
+
+// patchOrientation returns the direction from (x, y) to the intensity
+// centroid of the disc of the patch around it.
+func patchOrientation(lum *FloatImage, x, y int) float64 {
+	var m10, m01 float64
+	for dy := -briefPatchRadius; dy <= briefPatchRadius; dy++ {
+		for dx := -briefPatchRadius; dx <= briefPatchRadius; dx++ {
+			if dx*dx+dy*dy > briefPatchRadius*briefPatchRadius {
+				continue
+			}
+			v := float64(lum.FloatAt(x+dx, y+dy, 0))
+			m10 += float64(dx) * v
+			m01 += float64(dy) * v
+		}
+	}
+	return math.Atan2(m01, m10)
+}
+
+// Match is a pair of features of two images with similar descriptors
+type Match struct {
+	// A and B are the indices of the features in the slices passed to
+	// MatchFeatures.
+	A, B int
+	// Distance is the Hamming distance between the descriptors
+	Distance int
+}
+
+// MatchOptions are the options of MatchFeatures. A nil *MatchOptions means the
+// defaults are used.
+type MatchOptions struct {
+	// Ratio drops the matches whose distance isn't clearly lower than the
+	// one of the second best candidate: the ratio between the two distances
+	// must be lower than this value. The default is 0.8; 1 disables the test.
+	Ratio float64
+	// CrossCheck only keeps the matches where the feature of A is also the
+	// best match of the feature of B.
+	CrossCheck bool
+	// MaxDistance drops the matches whose distance is higher; 0 means no
+	// limit.
+	MaxDistance int
+}
+
+// MatchFeatures finds the best match in b of each feature of a by comparing
+// all their descriptors. It returns the matches from the closest to the
+// farthest.
+func MatchFeatures(a, b []Feature, opts *MatchOptions) []Match {
+	if opts == nil {
+		opts = &MatchOptions{}
+	}
+	ratio := opts.Ratio
+	if ratio <= 0 {
+		ratio = defaultMatchRatio
+	}
+
+	// nearest returns the index of the closest feature of features to f, its
+	// distance and the distance of the second closest one.
+	nearest := func(f Feature, features []Feature) (int, int, int) {
+		best, bestDistance, second := -1, math.MaxInt32, math.MaxInt32
+		for i, g := range features {
+			d := f.Descriptor.Distance(g.Descriptor)
+			if d < bestDistance {
+				best, bestDistance, second = i, d, bestDistance
+			} else if d < second {
+				second = d
+			}
+		}
+		return best, bestDistance, second
+	}
+
+	var matches []Match
+	for i, f := range a {
+		j, d, second := nearest(f, b)
+		if j < 0 {
+			break
+		}
+		if opts.MaxDistance > 0 && d > opts.MaxDistance {
+			continue
+		}
+		// There's no second candidate if b only has one feature
+		if ratio < 1 && second != math.MaxInt32 && float64(d) >= ratio*float64(second) {
+			continue
+		}
+		if opts.CrossCheck {
+			if back, _, _ := nearest(b[j], a); back != i {
+				continue
+			}
+		}
+		matches = append(matches, Match{A: i, B: j, Distance: d})
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Distance < matches[j].Distance
+	})
+	return matches
+}
+
+// MatchedPoints returns the positions of the matched features of a and b, in
+// the order of the matches, e.g. to fit a HomographyModel with RANSAC.
+func MatchedPoints(a, b []Feature, matches []Match) (src, dst []image.Point) {
+	src = make([]image.Point, len(matches))
+	dst = make([]image.Point, len(matches))
+	for i, m := range matches {
+		src[i] = image.Point{a[m.A].X, a[m.A].Y}
+		dst[i] = image.Point{b[m.B].X, b[m.B].Y}
+	}
+	return src, dst
+}
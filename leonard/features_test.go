@@ -0,0 +1,158 @@
+package leonard
+
+import (
+	"image"
+	"math/rand"
+	"testing"
+)
+
+// blocks returns a w×h image made of random gray 4×4 blocks
+func blocks(w, h int, seed int64) *image.Gray {
+	r := rand.New(rand.NewSource(seed))
+	levels := make([]uint8, (w/4+1)*(h/4+1))
+	for i := range levels {
+		levels[i] = uint8(r.Intn(256))
+	}
+	return newGrayImage(w, h, func(x, y int) uint8 {
+		return levels[(y/4)*(w/4+1)+x/4]
+	})
+}
+
+// rotate90 rotates an image by 90° clockwise
+func rotate90(img *image.Gray) *image.Gray {
+	b := img.Bounds()
+	return newGrayImage(b.Dy(), b.Dx(), func(x, y int) uint8 {
+		return img.GrayAt(y, b.Dy()-1-x).Y
+	})
+}
+
+func TestDescriptorDistance(t *testing.T) {
+	a := Descriptor{0, 0, 0, 0}
+	b := Descriptor{1, 3, 0, 1 << 63}
+
+	if d := a.Distance(b); d != 4 {
+		t.Errorf("got %d, want 4", d)
+	}
+	if d := b.Distance(b); d != 0 {
+		t.Errorf("got %d, want 0", d)
+	}
+	if d := a.Distance(Descriptor{^uint64(0), ^uint64(0), ^uint64(0), ^uint64(0)}); d != 256 {
+		t.Errorf("got %d, want 256", d)
+	}
+}
+
+func TestDescribeDropsBorders(t *testing.T) {
+	img := blocks(64, 64, 1)
+	keypoints := []Keypoint{{X: 5, Y: 32}, {X: 32, Y: 32}, {X: 32, Y: 60}, {X: 40, Y: 20}}
+
+	features := Describe(img, keypoints, nil)
+	if len(features) != 2 {
+		t.Fatalf("got %d features, want 2", len(features))
+	}
+	if features[0].X != 32 || features[1].X != 40 {
+		t.Errorf("got features at %v and %v", features[0].Keypoint, features[1].Keypoint)
+	}
+}
+
+func TestMatchFeaturesRotation(t *testing.T) {
+	img := blocks(96, 96, 2)
+	rotated := rotate90(img)
+
+	corners, err := Corners(img, &CornerOptions{Detector: FAST, Max: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := Describe(img, corners, nil)
+
+	// the same keypoints in the rotated image
+	moved := make([]Keypoint, len(corners))
+	for i, k := range corners {
+		moved[i] = Keypoint{X: 95 - k.Y, Y: k.X}
+	}
+	b := Describe(rotated, moved, nil)
+
+	if len(a) < 10 || len(a) != len(b) {
+		t.Fatalf("got %d and %d features", len(a), len(b))
+	}
+
+	// matches pair a feature with its rotated copy
+	good := 0
+	for _, m := range MatchFeatures(a, b, &MatchOptions{CrossCheck: true}) {
+		if m.A == m.B {
+			good++
+		}
+	}
+	if good < len(a)*3/4 {
+		t.Errorf("got %d correct matches out of %d features", good, len(a))
+	}
+
+	// upright descriptors aren't invariant to rotations
+	a = Describe(img, corners, &DescriptorOptions{Upright: true})
+	b = Describe(rotated, moved, &DescriptorOptions{Upright: true})
+	uprightGood := 0
+	for _, m := range MatchFeatures(a, b, &MatchOptions{CrossCheck: true}) {
+		if m.A == m.B {
+			uprightGood++
+		}
+	}
+	if uprightGood >= good {
+		t.Errorf("got %d correct upright matches, want less than %d", uprightGood, good)
+	}
+}
+
+func TestMatchFeatures(t *testing.T) {
+	a := []Feature{
+		{Descriptor: Descriptor{0}},
+		{Descriptor: Descriptor{0xFF}},
+	}
+	b := []Feature{
+		{Descriptor: Descriptor{0xFF, 1}},
+		{Descriptor: Descriptor{1}},
+		{Descriptor: Descriptor{3}},
+	}
+
+	matches := MatchFeatures(a, b, &MatchOptions{Ratio: 1})
+	want := []Match{{A: 0, B: 1, Distance: 1}, {A: 1, B: 0, Distance: 1}}
+	if len(matches) != len(want) || matches[0] != want[0] || matches[1] != want[1] {
+		t.Errorf("got %v, want %v", matches, want)
+	}
+
+	// the two closest candidates are at 1 and 2 for a[0], 1 and 6 for a[1]
+	matches = MatchFeatures(a, b, &MatchOptions{Ratio: 0.5})
+	if len(matches) != 1 || matches[0].A != 1 {
+		t.Errorf("got %v, want only the match of a[1]", matches)
+	}
+
+	// the closest candidate of 0xF is at 2
+	c := []Feature{{Descriptor: Descriptor{0xF}}}
+	if matches = MatchFeatures(c, b, &MatchOptions{Ratio: 1, MaxDistance: 1}); len(matches) != 0 {
+		t.Errorf("MaxDistance=1: got %v, want no match", matches)
+	}
+	if matches = MatchFeatures(c, b, &MatchOptions{Ratio: 1, MaxDistance: 2}); len(matches) != 1 {
+		t.Errorf("MaxDistance=2: got %v, want 1 match", matches)
+	}
+	if matches = MatchFeatures(a, nil, nil); len(matches) != 0 {
+		t.Errorf("got %v, want no match", matches)
+	}
+}
+
+func TestMatchFeaturesCrossCheck(t *testing.T) {
+	// both features of a are closest to b[0], which is closest to a[1]
+	a := []Feature{{Descriptor: Descriptor{0x7}}, {Descriptor: Descriptor{0x3}}}
+	b := []Feature{{Descriptor: Descriptor{0x1}}, {Descriptor: Descriptor{0xFFFF}}}
+
+	matches := MatchFeatures(a, b, &MatchOptions{Ratio: 1, CrossCheck: true})
+	if len(matches) != 1 || matches[0].A != 1 || matches[0].B != 0 {
+		t.Errorf("got %v, want a[1]-b[0]", matches)
+	}
+}
+
+func TestMatchedPoints(t *testing.T) {
+	a := []Feature{{Keypoint: Keypoint{X: 1, Y: 2}}, {Keypoint: Keypoint{X: 3, Y: 4}}}
+	b := []Feature{{Keypoint: Keypoint{X: 5, Y: 6}}}
+
+	src, dst := MatchedPoints(a, b, []Match{{A: 1, B: 0}})
+	if len(src) != 1 || src[0] != (image.Point{3, 4}) || dst[0] != (image.Point{5, 6}) {
+		t.Errorf("got %v and %v", src, dst)
+	}
+}
@@ -10,6 +10,16 @@ func absint(n int) int {
 	return int(math.Abs(float64(n)))
 }
 
+func clampInt(n, min, max int) int {
+	if n < min {
+		return min
+	}
+	if n > max {
+		return max
+	}
+	return n
+}
+
 func gaussianKernel(x float64, sigma float64) float64 {
 	// The Gaussian filter is the convolution between a kernel and the image
 	// matrix [1,2,3,4,5,6].
@@ -52,13 +62,17 @@ func GaussianFilter(img image.Image, sigma float64) image.Image {
 	}
 
 	bounds := img.Bounds()
-	blured := image.NewRGBA(bounds)
+	width := bounds.Dx()
+
+	// The result of the first pass is kept as floats so that we don't lose
+	// precision before the second one. Colors are alpha-premultiplied.
+	columns := make([]float32, width*bounds.Dy()*4)
 
 	// columns
 	for x := bounds.Min.X; x < bounds.Max.X; x++ {
 		start := x - radius
-		if start < 0 {
-			start = 0
+		if start < bounds.Min.X {
+			start = bounds.Min.X
 		}
 
 		end := x + radius
@@ -79,25 +93,27 @@ func GaussianFilter(img image.Image, sigma float64) image.Image {
 				ir, ig, ib, ia := img.At(ix, y).RGBA()
 
 				weight := kernel[absint(x-ix)]
-				r += weight * to255(float64(ir))
-				g += weight * to255(float64(ig))
-				b += weight * to255(float64(ib))
-				a += weight * to255(float64(ia))
+				r += weight * float64(ir)
+				g += weight * float64(ig)
+				b += weight * float64(ib)
+				a += weight * float64(ia)
 			}
 
-			blured.Set(x, y, color.RGBA{
-				uint8(r / weightsSum),
-				uint8(g / weightsSum),
-				uint8(b / weightsSum),
-				uint8(a / weightsSum),
-			})
+			i := ((y-bounds.Min.Y)*width + x - bounds.Min.X) * 4
+			columns[i] = float32(r / weightsSum)
+			columns[i+1] = float32(g / weightsSum)
+			columns[i+2] = float32(b / weightsSum)
+			columns[i+3] = float32(a / weightsSum)
 		}
 	}
 
+	// The result has the same depth as the original image
+	blured := newImageLike(bounds, img)
+
 	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
 		start := y - radius
-		if start < 0 {
-			start = 0
+		if start < bounds.Min.Y {
+			start = bounds.Min.Y
 		}
 
 		end := y + radius
@@ -115,23 +131,67 @@ func GaussianFilter(img image.Image, sigma float64) image.Image {
 			var r, g, b, a float64
 
 			for iy := start; iy <= end; iy++ {
-				ir, ig, ib, ia := blured.At(x, iy).RGBA()
+				i := ((iy-bounds.Min.Y)*width + x - bounds.Min.X) * 4
 
 				weight := kernel[absint(y-iy)]
-				r += weight * to255(float64(ir))
-				g += weight * to255(float64(ig))
-				b += weight * to255(float64(ib))
-				a += weight * to255(float64(ia))
+				r += weight * float64(columns[i])
+				g += weight * float64(columns[i+1])
+				b += weight * float64(columns[i+2])
+				a += weight * float64(columns[i+3])
 			}
 
-			blured.Set(x, y, color.RGBA{
-				uint8(r / weightsSum),
-				uint8(g / weightsSum),
-				uint8(b / weightsSum),
-				uint8(a / weightsSum),
+			blured.Set(x, y, color.RGBA64{
+				uint16(r/weightsSum + 0.5),
+				uint16(g/weightsSum + 0.5),
+				uint16(b/weightsSum + 0.5),
+				uint16(a/weightsSum + 0.5),
 			})
 		}
 	}
 
 	return blured
 }
+
+// gaussianFilterFloat is like GaussianFilter but works on the samples of a
+// FloatImage, without clamping nor rounding them.
+func gaussianFilterFloat(f *FloatImage, sigma float64) *FloatImage {
+	radius := int(math.Ceil(sigma * 2.0))
+
+	kernel := make([]float32, radius+1)
+	for i := range kernel {
+		kernel[i] = float32(gaussianKernel(float64(i), sigma))
+	}
+
+	bounds := f.Rect
+	w, h := bounds.Dx(), bounds.Dy()
+	channels := f.Channels
+
+	// pass applies the kernel along one direction: n is the length of the
+	// lines and at returns the index of the sample of channel c at position
+	// k on line l.
+	pass := func(src *FloatImage, lines, n int, at func(l, k, c int) int) *FloatImage {
+		dst := NewFloatImage(bounds, channels)
+		for l := 0; l < lines; l++ {
+			for k := 0; k < n; k++ {
+				start, end := clampInt(k-radius, 0, n-1), clampInt(k+radius, 0, n-1)
+
+				var weightsSum float32
+				for ik := start; ik <= end; ik++ {
+					weightsSum += kernel[absint(k-ik)]
+				}
+
+				for c := 0; c < channels; c++ {
+					var v float32
+					for ik := start; ik <= end; ik++ {
+						v += kernel[absint(k-ik)] * src.Pix[at(l, ik, c)]
+					}
+					dst.Pix[at(l, k, c)] = v / weightsSum
+				}
+			}
+		}
+		return dst
+	}
+
+	rows := pass(f, h, w, func(y, x, c int) int { return y*f.Stride + x*channels + c })
+	return pass(rows, w, h, func(x, y, c int) int { return y*f.Stride + x*channels + c })
+}
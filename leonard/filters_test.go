@@ -0,0 +1,66 @@
+package leonard
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// newGray16Image returns a w×h 16-bit grayscale image whose pixels are given
+// by fn
+func newGray16Image(w, h int, fn func(x, y int) uint16) *image.Gray16 {
+	img := image.NewGray16(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetGray16(x, y, color.Gray16{fn(x, y)})
+		}
+	}
+	return img
+}
+
+func absDiff16(a, b uint16) int {
+	return absint(int(a) - int(b))
+}
+
+func TestGaussianFilterGray16(t *testing.T) {
+	// a ramp of 1/65535 per pixel, lost if the image goes through 8 bits
+	img := newGray16Image(20, 5, func(x, y int) uint16 { return 1000 + uint16(x) })
+
+	blurred := GaussianFilter(img, 1)
+	if _, ok := blurred.(*image.Gray16); !ok {
+		t.Fatalf("got a %T, want an *image.Gray16", blurred)
+	}
+
+	// a linear ramp is left unchanged away from the borders
+	for x := 3; x < 17; x++ {
+		if got, want := gray16At(blurred, x, 2), 1000+uint16(x); absDiff16(got, want) > 0 {
+			t.Errorf("got %d at x=%d, want %d", got, x, want)
+		}
+	}
+}
+
+func TestGaussianFilterRGBA64(t *testing.T) {
+	c := color.RGBA64{0x1234, 0x5678, 0x9abc, 0xffff}
+	img := image.NewRGBA64(image.Rect(0, 0, 8, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			img.SetRGBA64(x, y, c)
+		}
+	}
+
+	blurred := GaussianFilter(img, 2)
+	out, ok := blurred.(*image.RGBA64)
+	if !ok {
+		t.Fatalf("got a %T, want an *image.RGBA64", blurred)
+	}
+	got := out.RGBA64At(4, 4)
+	if absDiff16(got.R, c.R) > 1 || absDiff16(got.G, c.G) > 1 || absDiff16(got.B, c.B) > 1 || got.A != c.A {
+		t.Errorf("got %v, want %v", got, c)
+	}
+}
+
+func TestGaussianFilterGray(t *testing.T) {
+	if _, ok := GaussianFilter(uniformGray(4, 4, 100), 1).(*image.Gray); !ok {
+		t.Error("expected an 8-bit grayscale image")
+	}
+}
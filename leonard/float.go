@@ -0,0 +1,313 @@
+package leonard
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"sort"
+)
+
+// FloatImage is an image with float32 samples and any number of channels. It's
+// meant to hold intermediate results that can be negative or higher than the
+// maximum value of a color, e.g. signed gradients.
+//
+// When seen as an image.Image, samples are expected to be in the 0-1 range and
+// are clamped. Use Normalize or Image to choose how they're mapped.
+//
+// Images with 1 channel are grayscale, 3 channels are RGB and 4 channels are
+// RGBA with non-premultiplied alpha. Other numbers of channels are shown as
+// grayscale using the first one.
+type FloatImage struct {
+	// Pix holds the image's samples; the sample of channel c at (x, y) starts
+	// at Pix[(y-Rect.Min.Y)*Stride + (x-Rect.Min.X)*Channels + c].
+	Pix      []float32
+	Stride   int
+	Channels int
+	Rect     image.Rectangle
+}
+
+var _ image.Image = &FloatImage{}
+
+// NewFloatImage returns a new FloatImage with the given bounds and number of
+// channels. All samples are 0.
+func NewFloatImage(r image.Rectangle, channels int) *FloatImage {
+	return &FloatImage{
+		Pix:      make([]float32, r.Dx()*r.Dy()*channels),
+		Stride:   r.Dx() * channels,
+		Channels: channels,
+		Rect:     r,
+	}
+}
+
+// NewFloatImageFrom converts an image into a FloatImage with the given number
+// of channels: 1 for the luminance, 3 for RGB or 4 for RGBA. Samples are in the
+// 0-1 range.
+func NewFloatImageFrom(img image.Image, channels int) *FloatImage {
+	if channels != 1 && channels != 3 && channels != 4 {
+		panic("NewFloatImageFrom: channels must be 1, 3 or 4")
+	}
+
+	bounds := img.Bounds()
+	f := NewFloatImage(bounds, channels)
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			i := f.PixOffset(x, y)
+			c := img.At(x, y)
+
+			if channels == 1 {
+				f.Pix[i] = luminance(c) / 0xFFFF
+				continue
+			}
+
+			nc := color.NRGBA64Model.Convert(c).(color.NRGBA64)
+			f.Pix[i] = float32(nc.R) / 0xFFFF
+			f.Pix[i+1] = float32(nc.G) / 0xFFFF
+			f.Pix[i+2] = float32(nc.B) / 0xFFFF
+			if channels == 4 {
+				f.Pix[i+3] = float32(nc.A) / 0xFFFF
+			}
+		}
+	}
+
+	return f
+}
+
+// ColorModel implements the image.Image interface
+func (f *FloatImage) ColorModel() color.Model {
+	if f.Channels == 3 || f.Channels == 4 {
+		return color.NRGBA64Model
+	}
+	return color.Gray16Model
+}
+
+// Bounds implements the image.Image interface
+func (f *FloatImage) Bounds() image.Rectangle {
+	return f.Rect
+}
+
+func clamp01(v float32) float32 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
+
+func float16(v float32) uint16 {
+	return uint16(clamp01(v)*0xFFFF + 0.5)
+}
+
+// At implements the image.Image interface
+func (f *FloatImage) At(x, y int) color.Color {
+	if !(image.Point{x, y}.In(f.Rect)) {
+		if f.ColorModel() == color.Gray16Model {
+			return color.Gray16{}
+		}
+		return color.NRGBA64{}
+	}
+
+	i := f.PixOffset(x, y)
+
+	switch f.Channels {
+	case 3:
+		return color.NRGBA64{float16(f.Pix[i]), float16(f.Pix[i+1]), float16(f.Pix[i+2]), 0xFFFF}
+	case 4:
+		return color.NRGBA64{float16(f.Pix[i]), float16(f.Pix[i+1]), float16(f.Pix[i+2]), float16(f.Pix[i+3])}
+	default:
+		return color.Gray16{float16(f.Pix[i])}
+	}
+}
+
+// PixOffset returns the index of the first sample of the pixel at (x, y) in
+// Pix.
+func (f *FloatImage) PixOffset(x, y int) int {
+	return (y-f.Rect.Min.Y)*f.Stride + (x-f.Rect.Min.X)*f.Channels
+}
+
+// FloatAt returns the sample of channel c at (x, y)
+func (f *FloatImage) FloatAt(x, y, c int) float32 {
+	if !(image.Point{x, y}.In(f.Rect)) {
+		return 0
+	}
+	return f.Pix[f.PixOffset(x, y)+c]
+}
+
+// SetFloat sets the sample of channel c at (x, y)
+func (f *FloatImage) SetFloat(x, y, c int, v float32) {
+	if !(image.Point{x, y}.In(f.Rect)) {
+		return
+	}
+	f.Pix[f.PixOffset(x, y)+c] = v
+}
+
+// Clone returns a copy of the image
+func (f *FloatImage) Clone() *FloatImage {
+	f2 := *f
+	f2.Pix = append([]float32(nil), f.Pix...)
+	return &f2
+}
+
+// Channel returns a new 1-channel image with the given channel of this one
+func (f *FloatImage) Channel(c int) *FloatImage {
+	ch := NewFloatImage(f.Rect, 1)
+	for y := f.Rect.Min.Y; y < f.Rect.Max.Y; y++ {
+		for x := f.Rect.Min.X; x < f.Rect.Max.X; x++ {
+			ch.Pix[ch.PixOffset(x, y)] = f.Pix[f.PixOffset(x, y)+c]
+		}
+	}
+	return ch
+}
+
+// MinMax returns the minimum and maximum samples of the image, across all
+// channels.
+func (f *FloatImage) MinMax() (min, max float32) {
+	if len(f.Pix) == 0 {
+		return 0, 0
+	}
+
+	min, max = f.Pix[0], f.Pix[0]
+	for _, v := range f.Pix {
+		if v < min {
+			min = v
+		} else if v > max {
+			max = v
+		}
+	}
+	return
+}
+
+// colorMinMax is like MinMax but ignores the alpha channel of RGBA images
+func (f *FloatImage) colorMinMax() (min, max float32) {
+	if f.Channels != 4 {
+		return f.MinMax()
+	}
+
+	first := true
+	for i, v := range f.Pix {
+		if i%4 == 3 {
+			continue
+		}
+		if first || v < min {
+			min = v
+		}
+		if first || v > max {
+			max = v
+		}
+		first = false
+	}
+	return
+}
+
+// Percentile returns the sample below which the given fraction, between 0 and
+// 1, of the samples of the image fall. Percentile(0.5) is the median.
+func (f *FloatImage) Percentile(p float64) float32 {
+	if len(f.Pix) == 0 {
+		return 0
+	}
+
+	sorted := append([]float32(nil), f.Pix...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	i := int(math.Ceil(p*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i]
+}
+
+// FloatPolicy defines how the samples of a FloatImage are mapped to the 0-1
+// range.
+type FloatPolicy int
+
+const (
+	// ClampPolicy clamps the samples in the 0-1 range
+	ClampPolicy FloatPolicy = iota
+	// MinMaxPolicy maps the minimum sample to 0 and the maximum one to 1
+	MinMaxPolicy
+	// AbsPolicy maps absolute values to 0-1 by dividing them by the maximum
+	// one. Use it for magnitudes.
+	AbsPolicy
+	// SignedPolicy maps 0 to 0.5 and the highest absolute value to 0 or 1
+	// depending on its sign. Use it to show signed values, e.g. gradients.
+	SignedPolicy
+)
+
+// Normalize maps all the samples in the 0-1 range in-place, according to the
+// given policy, and returns the image. Normalizing an image whose samples are
+// all equal gives 0 with MinMaxPolicy and AbsPolicy and 0.5 with SignedPolicy.
+//
+// The alpha channel of RGBA images is only clamped, so that opaque pixels stay
+// opaque whatever the policy.
+func (f *FloatImage) Normalize(policy FloatPolicy) *FloatImage {
+	var fn func(float32) float32
+
+	switch policy {
+	case MinMaxPolicy:
+		min, max := f.colorMinMax()
+		if max == min {
+			fn = func(float32) float32 { return 0 }
+		} else {
+			scale := 1 / (max - min)
+			fn = func(v float32) float32 { return (v - min) * scale }
+		}
+
+	case AbsPolicy, SignedPolicy:
+		min, max := f.colorMinMax()
+		absMax := float32(math.Max(math.Abs(float64(min)), math.Abs(float64(max))))
+
+		if policy == AbsPolicy {
+			if absMax == 0 {
+				fn = func(float32) float32 { return 0 }
+			} else {
+				fn = func(v float32) float32 {
+					return float32(math.Abs(float64(v))) / absMax
+				}
+			}
+		} else {
+			if absMax == 0 {
+				fn = func(float32) float32 { return 0.5 }
+			} else {
+				fn = func(v float32) float32 { return 0.5 + v/(2*absMax) }
+			}
+		}
+
+	default:
+		fn = clamp01
+	}
+
+	for i, v := range f.Pix {
+		if f.Channels == 4 && i%4 == 3 {
+			f.Pix[i] = clamp01(v)
+		} else {
+			f.Pix[i] = fn(v)
+		}
+	}
+	return f
+}
+
+// Image converts the FloatImage into a 16-bit image after having mapped its
+// samples with the given policy. The FloatImage is not modified.
+func (f *FloatImage) Image(policy FloatPolicy) image.Image {
+	n := f.Clone().Normalize(policy)
+
+	if n.ColorModel() == color.Gray16Model {
+		img := image.NewGray16(f.Rect)
+		for y := f.Rect.Min.Y; y < f.Rect.Max.Y; y++ {
+			for x := f.Rect.Min.X; x < f.Rect.Max.X; x++ {
+				img.SetGray16(x, y, color.Gray16{float16(n.Pix[n.PixOffset(x, y)])})
+			}
+		}
+		return img
+	}
+
+	img := image.NewNRGBA64(f.Rect)
+	for y := f.Rect.Min.Y; y < f.Rect.Max.Y; y++ {
+		for x := f.Rect.Min.X; x < f.Rect.Max.X; x++ {
+			img.Set(x, y, n.At(x, y))
+		}
+	}
+	return img
+}
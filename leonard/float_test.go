@@ -0,0 +1,93 @@
+package leonard
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+// floatRow returns a 1-channel image with a single row of samples
+func floatRow(samples ...float32) *FloatImage {
+	f := NewFloatImage(image.Rect(0, 0, len(samples), 1), 1)
+	copy(f.Pix, samples)
+	return f
+}
+
+func equalSamples(got, want []float32) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if math.Abs(float64(got[i]-want[i])) > 1e-6 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestNormalize(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		policy  FloatPolicy
+		samples []float32
+		want    []float32
+	}{
+		{"clamp", ClampPolicy, []float32{-0.5, 0.25, 1.5}, []float32{0, 0.25, 1}},
+		{"min-max", MinMaxPolicy, []float32{2, 4, 6}, []float32{0, 0.5, 1}},
+		{"min-max negative", MinMaxPolicy, []float32{-1, 0, 3}, []float32{0, 0.25, 1}},
+		{"min-max flat", MinMaxPolicy, []float32{3, 3}, []float32{0, 0}},
+		{"abs", AbsPolicy, []float32{-2, 1, 0}, []float32{1, 0.5, 0}},
+		{"abs flat", AbsPolicy, []float32{0, 0}, []float32{0, 0}},
+		{"signed", SignedPolicy, []float32{-2, 1, 0}, []float32{0, 0.75, 0.5}},
+		{"signed flat", SignedPolicy, []float32{0, 0}, []float32{0.5, 0.5}},
+	} {
+		f := floatRow(tt.samples...)
+		if got := f.Normalize(tt.policy); got != f || !equalSamples(f.Pix, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, f.Pix, tt.want)
+		}
+	}
+}
+
+func TestNormalizeKeepsAlpha(t *testing.T) {
+	for _, tt := range []struct {
+		policy FloatPolicy
+		want   []float32
+	}{
+		{ClampPolicy, []float32{0.2, 0.4, 0.6, 1, 0.4, 0.4, 0.4, 0.5}},
+		{MinMaxPolicy, []float32{0, 0.5, 1, 1, 0.5, 0.5, 0.5, 0.5}},
+		{AbsPolicy, []float32{1.0 / 3, 2.0 / 3, 1, 1, 2.0 / 3, 2.0 / 3, 2.0 / 3, 0.5}},
+		{SignedPolicy, []float32{2.0 / 3, 5.0 / 6, 1, 1, 5.0 / 6, 5.0 / 6, 5.0 / 6, 0.5}},
+	} {
+		// an opaque pixel and a translucent one
+		f := NewFloatImage(image.Rect(0, 0, 2, 1), 4)
+		copy(f.Pix, []float32{0.2, 0.4, 0.6, 1, 0.4, 0.4, 0.4, 0.5})
+
+		if f.Normalize(tt.policy); !equalSamples(f.Pix, tt.want) {
+			t.Errorf("policy %d: got %v, want %v", tt.policy, f.Pix, tt.want)
+		}
+	}
+}
+
+func TestFloatImageImage(t *testing.T) {
+	f := floatRow(-1, 0, 1)
+
+	img := f.Image(SignedPolicy)
+	gray, ok := img.(*image.Gray16)
+	if !ok {
+		t.Fatalf("got a %T, want an *image.Gray16", img)
+	}
+	if got := []uint16{gray.Gray16At(0, 0).Y, gray.Gray16At(1, 0).Y, gray.Gray16At(2, 0).Y}; got[0] != 0 || got[1] != 0x8000 || got[2] != 0xFFFF {
+		t.Errorf("got %v, want 0, 0x8000 and 0xFFFF", got)
+	}
+	// the FloatImage isn't modified
+	if !equalSamples(f.Pix, []float32{-1, 0, 1}) {
+		t.Errorf("got samples %v", f.Pix)
+	}
+
+	rgba := NewFloatImage(image.Rect(0, 0, 1, 1), 4)
+	copy(rgba.Pix, []float32{2, 1, 0, 1})
+	if c := rgba.Image(MinMaxPolicy).At(0, 0); c != (color.NRGBA64{0xFFFF, 0x8000, 0, 0xFFFF}) {
+		t.Errorf("got %v, want an opaque orange", c)
+	}
+}
@@ -0,0 +1,279 @@
+package leonard
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+	"golang.org/x/image/webp"
+)
+
+var (
+	// ErrUnsupportedFormat is returned when an image format is unknown or
+	// doesn't support the requested operation.
+	ErrUnsupportedFormat = errors.New("unsupported format")
+
+	// ErrCorrupt is returned when an image can't be decoded
+	ErrCorrupt = errors.New("corrupt image")
+)
+
+// imageError is an error of one of the kinds above. It wraps the underlying
+// error, if any.
+type imageError struct {
+	kind error
+	msg  string
+	err  error
+}
+
+func (e *imageError) Error() string {
+	if e.err == nil {
+		return fmt.Sprintf("%s: %s", e.kind, e.msg)
+	}
+	// Most decoders already prefix their errors with the format name
+	if strings.HasPrefix(e.err.Error(), e.msg+":") {
+		return fmt.Sprintf("%s: %s", e.kind, e.err)
+	}
+	return fmt.Sprintf("%s: %s: %s", e.kind, e.msg, e.err)
+}
+
+func (e *imageError) Is(target error) bool { return target == e.kind }
+
+func (e *imageError) Unwrap() error { return e.err }
+
+func unsupportedFormat(format string, args ...interface{}) error {
+	return &imageError{kind: ErrUnsupportedFormat, msg: fmt.Sprintf(format, args...)}
+}
+
+// Format is an image format that can be read and/or written by LoadImage,
+// SaveImage and their variants.
+type Format struct {
+	// Name is the canonical name of the format, e.g. "jpeg"
+	Name string
+	// Aliases are other names accepted for the format, e.g. "jpg"
+	Aliases []string
+	// Extensions are the file extensions of the format, with their leading
+	// dot.
+	Extensions []string
+	// Magic are the possible first bytes of files in this format. '?' matches
+	// any byte.
+	Magic []string
+
+	// Decode is nil if the format can't be decoded
+	Decode func(io.Reader) (image.Image, error)
+	// Encode is nil if the format can't be encoded
+	Encode func(io.Writer, image.Image, *EncodeOptions) error
+}
+
+func (f *Format) matchMagic(header []byte) bool {
+	for _, magic := range f.Magic {
+		if len(header) < len(magic) {
+			continue
+		}
+		ok := true
+		for i := 0; i < len(magic); i++ {
+			if magic[i] != '?' && magic[i] != header[i] {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+var formats []*Format
+
+// RegisterFormat registers an image format. Formats registered later take
+// precedence over the others for the same name, alias or extension.
+func RegisterFormat(f *Format) {
+	formats = append([]*Format{f}, formats...)
+}
+
+// Formats returns the registered formats
+func Formats() []*Format {
+	return append([]*Format(nil), formats...)
+}
+
+// LookupFormat returns the format with the given name or alias
+func LookupFormat(name string) (*Format, error) {
+	name = strings.ToLower(name)
+	for _, f := range formats {
+		if f.Name == name {
+			return f, nil
+		}
+		for _, alias := range f.Aliases {
+			if alias == name {
+				return f, nil
+			}
+		}
+	}
+	return nil, unsupportedFormat("unknown format '%s'", name)
+}
+
+// ParseFormat returns the canonical name of an image format, e.g. "jpeg" for
+// "JPG". See LookupFormat.
+func ParseFormat(format string) (string, error) {
+	f, err := LookupFormat(format)
+	if err != nil {
+		return "", err
+	}
+	return f.Name, nil
+}
+
+// FormatFromFilename returns the name of the image format that corresponds to
+// a file extension. See LookupFormatByFilename.
+func FormatFromFilename(filename string) (string, error) {
+	f, err := LookupFormatByFilename(filename)
+	if err != nil {
+		return "", err
+	}
+	return f.Name, nil
+}
+
+// LookupFormatByFilename returns the format that corresponds to a file
+// extension. The default format for files without extension is PNG.
+func LookupFormatByFilename(filename string) (*Format, error) {
+	ext := strings.ToLower(filepath.Ext(filename))
+	if ext == "" {
+		return LookupFormat("png")
+	}
+
+	for _, f := range formats {
+		for _, e := range f.Extensions {
+			if e == ext {
+				return f, nil
+			}
+		}
+	}
+	return nil, unsupportedFormat("unknown extension '%s'", ext)
+}
+
+// maxMagicLength is the number of bytes needed by DetectFormat
+const maxMagicLength = 16
+
+// DetectFormat returns the format of an image from its first bytes
+func DetectFormat(header []byte) (*Format, error) {
+	for _, f := range formats {
+		if f.Decode != nil && f.matchMagic(header) {
+			return f, nil
+		}
+	}
+	return nil, unsupportedFormat("unknown image format")
+}
+
+func init() {
+	for _, f := range []*Format{
+		{
+			Name:       "png",
+			Extensions: []string{".png"},
+			Magic:      []string{"\x89PNG\r\n\x1a\n"},
+			Decode:     png.Decode,
+			Encode: func(w io.Writer, img image.Image, opts *EncodeOptions) error {
+				e := png.Encoder{CompressionLevel: opts.PNGCompression}
+				return e.Encode(w, img)
+			},
+		},
+		{
+			Name:       "jpeg",
+			Aliases:    []string{"jpg"},
+			Extensions: []string{".jpg", ".jpeg"},
+			Magic:      []string{"\xff\xd8"},
+			Decode:     jpeg.Decode,
+			Encode: func(w io.Writer, img image.Image, opts *EncodeOptions) error {
+				return jpeg.Encode(w, img, opts.JPEG)
+			},
+		},
+		{
+			Name:       "gif",
+			Extensions: []string{".gif"},
+			Magic:      []string{"GIF87a", "GIF89a"},
+			Decode:     gif.Decode,
+			Encode: func(w io.Writer, img image.Image, opts *EncodeOptions) error {
+				// The standard library uses the Plan 9 palette by default,
+				// which doesn't fit most images.
+				o := gif.Options{NumColors: 256}
+				if opts.GIF != nil {
+					o = *opts.GIF
+				}
+				if o.Quantizer == nil {
+					o.Quantizer = KMeansQuantizer{}
+				}
+				return gif.Encode(w, img, &o)
+			},
+		},
+		{
+			Name:       "bmp",
+			Extensions: []string{".bmp"},
+			Magic:      []string{"BM????\x00\x00\x00\x00"},
+			Decode:     bmp.Decode,
+			Encode: func(w io.Writer, img image.Image, _ *EncodeOptions) error {
+				return bmp.Encode(w, img)
+			},
+		},
+		{
+			Name:       "tiff",
+			Aliases:    []string{"tif"},
+			Extensions: []string{".tif", ".tiff"},
+			Magic:      []string{"II*\x00", "MM\x00*"},
+			Decode:     tiff.Decode,
+			Encode: func(w io.Writer, img image.Image, opts *EncodeOptions) error {
+				return tiff.Encode(w, img, opts.TIFF)
+			},
+		},
+		{
+			Name:       "webp",
+			Extensions: []string{".webp"},
+			Magic:      []string{"RIFF????WEBPVP8"},
+			Decode:     webp.Decode,
+		},
+		{
+			Name:       "pbm",
+			Extensions: []string{".pbm"},
+			Magic:      []string{"P1", "P4"},
+			Decode:     DecodeNetpbm,
+			Encode: func(w io.Writer, img image.Image, _ *EncodeOptions) error {
+				return EncodePBM(w, img)
+			},
+		},
+		{
+			Name:       "pgm",
+			Extensions: []string{".pgm"},
+			Magic:      []string{"P2", "P5"},
+			Decode:     DecodeNetpbm,
+			Encode: func(w io.Writer, img image.Image, _ *EncodeOptions) error {
+				return EncodePGM(w, img)
+			},
+		},
+		{
+			Name:       "ppm",
+			Extensions: []string{".ppm"},
+			Magic:      []string{"P3", "P6"},
+			Decode:     DecodeNetpbm,
+			Encode: func(w io.Writer, img image.Image, _ *EncodeOptions) error {
+				return EncodePPM(w, img)
+			},
+		},
+		{
+			// Any of the three formats above, picked from the image when
+			// encoding.
+			Name:       "pnm",
+			Extensions: []string{".pnm"},
+			Decode:     DecodeNetpbm,
+			Encode: func(w io.Writer, img image.Image, _ *EncodeOptions) error {
+				return EncodeNetpbm(w, img)
+			},
+		},
+	} {
+		RegisterFormat(f)
+	}
+}
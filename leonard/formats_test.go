@@ -0,0 +1,89 @@
+package leonard
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestParseFormat(t *testing.T) {
+	for name, want := range map[string]string{
+		"png":  "png",
+		"JPG":  "jpeg",
+		"jpeg": "jpeg",
+		"tif":  "tiff",
+		"pgm":  "pgm",
+	} {
+		if got, err := ParseFormat(name); err != nil || got != want {
+			t.Errorf("%s: got '%s' (%v), want '%s'", name, got, err, want)
+		}
+	}
+
+	if _, err := ParseFormat("xyz"); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("got %v, want an ErrUnsupportedFormat", err)
+	}
+}
+
+func TestFormatFromFilename(t *testing.T) {
+	for filename, want := range map[string]string{
+		"a.png":         "png",
+		"dir/b.JPEG":    "jpeg",
+		"no-extension":  "png",
+		"scan.ppm":      "ppm",
+		"photo.tif":     "tiff",
+		"archive.x.gif": "gif",
+	} {
+		if got, err := FormatFromFilename(filename); err != nil || got != want {
+			t.Errorf("%s: got '%s' (%v), want '%s'", filename, got, err, want)
+		}
+	}
+
+	if _, err := FormatFromFilename("a.xyz"); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("got %v, want an ErrUnsupportedFormat", err)
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	img := newGrayImage(5, 3, func(x, y int) uint8 { return uint8(40*x + 10*y) })
+
+	for _, format := range []string{"png", "bmp", "tiff", "pgm", "pnm"} {
+		var buf bytes.Buffer
+		if err := Encode(&buf, img, format, nil); err != nil {
+			t.Errorf("%s: %s", format, err)
+			continue
+		}
+
+		decoded, err := Decode(&buf)
+		if err != nil {
+			t.Errorf("%s: %s", format, err)
+			continue
+		}
+		for y := 0; y < 3; y++ {
+			for x := 0; x < 5; x++ {
+				if got, want := gray16At(decoded, x, y), gray16At(img, x, y); got != want {
+					t.Errorf("%s: got %d at (%d, %d), want %d", format, got, x, y, want)
+				}
+			}
+		}
+	}
+}
+
+func TestEncodeUnsupported(t *testing.T) {
+	var buf bytes.Buffer
+	err := Encode(&buf, uniformGray(2, 2, 0), "webp", nil)
+	if !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("got %v, want an ErrUnsupportedFormat", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("got %d bytes written, want none", buf.Len())
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	if _, err := Decode(bytes.NewReader([]byte("not an image"))); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("got %v, want an ErrUnsupportedFormat", err)
+	}
+	if _, err := Decode(bytes.NewReader([]byte("\x89PNG\r\n\x1a\n..."))); !errors.Is(err, ErrCorrupt) {
+		t.Errorf("got %v, want an ErrCorrupt", err)
+	}
+}
@@ -0,0 +1,114 @@
+package leonard
+
+import (
+	"fmt"
+	"image"
+)
+
+// Operation is an operation on images used in a Graph. It receives the images
+// produced by the node's inputs, in order.
+type Operation func(inputs ...image.Image) (image.Image, error)
+
+// UnaryOperation wraps a function that takes one image in an Operation
+func UnaryOperation(fn func(image.Image) image.Image) Operation {
+	return func(inputs ...image.Image) (image.Image, error) {
+		if len(inputs) != 1 {
+			return nil, fmt.Errorf("expected 1 input, got %d", len(inputs))
+		}
+		return fn(inputs[0]), nil
+	}
+}
+
+// BinaryOperation wraps a function that takes two images in an Operation
+func BinaryOperation(fn func(a, b image.Image) image.Image) Operation {
+	return func(inputs ...image.Image) (image.Image, error) {
+		if len(inputs) != 2 {
+			return nil, fmt.Errorf("expected 2 inputs, got %d", len(inputs))
+		}
+		return fn(inputs[0], inputs[1]), nil
+	}
+}
+
+type graphNode struct {
+	name   string
+	op     Operation
+	inputs []string
+}
+
+// Graph is a directed acyclic graph of operations on images. Each node has a
+// name and takes its inputs from other nodes, referred to by their names.
+// Nodes without an operation are the inputs of the graph.
+//
+// A node can only refer to nodes that were added before it; this guarantees
+// the graph has no cycle.
+type Graph struct {
+	nodes []*graphNode
+	index map[string]*graphNode
+}
+
+// NewGraph returns a new empty graph
+func NewGraph() *Graph {
+	return &Graph{index: make(map[string]*graphNode)}
+}
+
+func (g *Graph) add(n *graphNode) error {
+	if n.name == "" {
+		return fmt.Errorf("empty node name")
+	}
+	if _, ok := g.index[n.name]; ok {
+		return fmt.Errorf("duplicate node '%s'", n.name)
+	}
+	for _, input := range n.inputs {
+		if _, ok := g.index[input]; !ok {
+			return fmt.Errorf("%s: unknown input '%s'", n.name, input)
+		}
+	}
+
+	g.nodes = append(g.nodes, n)
+	g.index[n.name] = n
+	return nil
+}
+
+// Input declares an input of the graph
+func (g *Graph) Input(name string) error {
+	return g.add(&graphNode{name: name})
+}
+
+// Add adds a node that applies op on the images produced by the given inputs
+func (g *Graph) Add(name string, op Operation, inputs ...string) error {
+	if op == nil {
+		return fmt.Errorf("%s: no operation", name)
+	}
+	return g.add(&graphNode{name: name, op: op, inputs: inputs})
+}
+
+// Run executes the graph on the given input images and returns the images
+// produced by all its nodes, by name.
+func (g *Graph) Run(inputs map[string]image.Image) (map[string]image.Image, error) {
+	images := make(map[string]image.Image, len(g.nodes))
+
+	// Nodes are already in a topological order
+	for _, n := range g.nodes {
+		if n.op == nil {
+			img, ok := inputs[n.name]
+			if !ok {
+				return nil, fmt.Errorf("missing input '%s'", n.name)
+			}
+			images[n.name] = img
+			continue
+		}
+
+		args := make([]image.Image, len(n.inputs))
+		for i, input := range n.inputs {
+			args[i] = images[input]
+		}
+
+		img, err := n.op(args...)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", n.name, err)
+		}
+		images[n.name] = img
+	}
+
+	return images, nil
+}
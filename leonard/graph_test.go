@@ -0,0 +1,144 @@
+package leonard
+
+import (
+	"errors"
+	"image"
+	"strings"
+	"testing"
+)
+
+// namedImage is an empty image that remembers which node produced it
+type namedImage struct {
+	*image.Gray
+	name string
+}
+
+func newNamedImage(name string) image.Image {
+	return namedImage{image.NewGray(image.Rect(0, 0, 1, 1)), name}
+}
+
+func TestGraphRun(t *testing.T) {
+	var calls []string
+	var bInputs []string
+
+	op := func(name string) Operation {
+		return func(inputs ...image.Image) (image.Image, error) {
+			calls = append(calls, name)
+			if name == "b" {
+				for _, img := range inputs {
+					bInputs = append(bInputs, img.(namedImage).name)
+				}
+			}
+			return newNamedImage(name), nil
+		}
+	}
+
+	g := NewGraph()
+	for _, err := range []error{
+		g.Input("in"),
+		g.Add("a", op("a"), "in"),
+		g.Add("b", op("b"), "a", "in", "a"),
+		g.Add("c", op("c"), "in"),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	images, err := g.Run(map[string]image.Image{"in": newNamedImage("in")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(calls, ",") != "a,b,c" {
+		t.Errorf("got calls %v, want a, b and c", calls)
+	}
+	if strings.Join(bInputs, ",") != "a,in,a" {
+		t.Errorf("got inputs %v for b, want a, in and a", bInputs)
+	}
+	for _, name := range []string{"in", "a", "b", "c"} {
+		if img, ok := images[name]; !ok || img.(namedImage).name != name {
+			t.Errorf("got %v for %s", img, name)
+		}
+	}
+}
+
+func TestGraphAddErrors(t *testing.T) {
+	identity := UnaryOperation(func(img image.Image) image.Image { return img })
+
+	g := NewGraph()
+	if err := g.Input("in"); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Add("a", identity, "in"); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, err := range map[string]error{
+		"empty name":      g.Add("", identity, "in"),
+		"duplicate node":  g.Add("a", identity, "in"),
+		"duplicate input": g.Input("in"),
+		"unknown input":   g.Add("b", identity, "c"),
+		"no operation":    g.Add("b", nil, "in"),
+	} {
+		if err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	// a node can't refer to a node added after it
+	if err := g.Add("c", identity, "d"); err == nil {
+		t.Error("expected an error")
+	}
+	if err := g.Add("d", identity, "c"); err == nil {
+		t.Error("expected an error for an input that failed to be added")
+	}
+}
+
+func TestGraphRunMissingInput(t *testing.T) {
+	g := NewGraph()
+	g.Input("in")
+	g.Input("other")
+	g.Add("a", BinaryOperation(Add), "in", "other")
+
+	if _, err := g.Run(map[string]image.Image{"in": uniformGray(2, 2, 0)}); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestGraphRunArity(t *testing.T) {
+	img := uniformGray(2, 2, 0)
+	identity := UnaryOperation(func(img image.Image) image.Image { return img })
+
+	for name, build := range map[string]func(g *Graph){
+		"unary with 2 inputs":  func(g *Graph) { g.Add("a", identity, "in", "in") },
+		"unary with no input":  func(g *Graph) { g.Add("a", identity) },
+		"binary with 1 input":  func(g *Graph) { g.Add("a", BinaryOperation(Add), "in") },
+		"binary with 3 inputs": func(g *Graph) { g.Add("a", BinaryOperation(Add), "in", "in", "in") },
+	} {
+		g := NewGraph()
+		g.Input("in")
+		build(g)
+
+		_, err := g.Run(map[string]image.Image{"in": img})
+		if err == nil || !strings.HasPrefix(err.Error(), "a: ") {
+			t.Errorf("%s: got %v, want an error from a", name, err)
+		}
+	}
+}
+
+func TestGraphRunError(t *testing.T) {
+	failed := errors.New("failed")
+
+	g := NewGraph()
+	g.Input("in")
+	g.Add("a", func(...image.Image) (image.Image, error) { return nil, failed }, "in")
+	g.Add("b", func(...image.Image) (image.Image, error) {
+		t.Error("b was run after a failed")
+		return nil, nil
+	}, "a")
+
+	if _, err := g.Run(map[string]image.Image{"in": uniformGray(2, 2, 0)}); err == nil || err.Error() != "a: failed" {
+		t.Errorf("got %v, want 'a: failed'", err)
+	}
+}
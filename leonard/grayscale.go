@@ -28,10 +28,71 @@ func Grayscale(img image.Image) image.Image {
 	return grayscaled
 }
 
-// Binary returns a binary image. Use NewBinaryImage to use a custom threshold.
+// Binary returns a binary image. Use NewBinaryImage to use a custom threshold;
+// see also OtsuThreshold.
 func Binary(img image.Image) image.Image {
-	// TODO Implement Othsu's method to get the correct theshold:
+	return NewBinaryImage(img, int(math.Ceil(0xffff*0.6)))
+}
+
+// OtsuThreshold returns the luminance threshold (0-0xFFFF) that best separates
+// the image's pixels in two classes, suitable for NewBinaryImage.
+func OtsuThreshold(img image.Image) int {
 	// https://en.wikipedia.org/wiki/Otsu%27s_method
 	// http://ijetch.org/papers/260-T754.pdf
-	return NewBinaryImage(img, int(math.Ceil(0xffff*0.6)))
+
+	// We use 256 bins; more is slower without giving much better results.
+	var histogram [256]int
+
+	bd := img.Bounds()
+	for y := bd.Min.Y; y < bd.Max.Y; y++ {
+		for x := bd.Min.X; x < bd.Max.X; x++ {
+			bin := int(luminance(img.At(x, y))) >> 8
+			if bin > 0xff {
+				bin = 0xff
+			}
+			histogram[bin]++
+		}
+	}
+
+	total := bd.Dx() * bd.Dy()
+	if total == 0 {
+		return 0
+	}
+
+	sum := 0.0
+	for i, n := range histogram {
+		sum += float64(i * n)
+	}
+
+	// Maximize the between-class variance
+	bestBin := 0
+	bestVariance := -1.0
+	sumBackground := 0.0
+	weightBackground := 0
+
+	for i, n := range histogram {
+		weightBackground += n
+		if weightBackground == 0 {
+			continue
+		}
+		weightForeground := total - weightBackground
+		if weightForeground == 0 {
+			break
+		}
+
+		sumBackground += float64(i * n)
+
+		meanBackground := sumBackground / float64(weightBackground)
+		meanForeground := (sum - sumBackground) / float64(weightForeground)
+		diff := meanBackground - meanForeground
+
+		variance := float64(weightBackground) * float64(weightForeground) * diff * diff
+		if variance > bestVariance {
+			bestVariance = variance
+			bestBin = i
+		}
+	}
+
+	// Pixels in bestBin belong to the background
+	return (bestBin + 1) << 8
 }
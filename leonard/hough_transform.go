@@ -1,6 +1,9 @@
 package leonard
 
-import "math"
+import (
+	"image"
+	"math"
+)
 
 const (
 	// we only support 3 directions for now
@@ -20,7 +23,8 @@ const (
 // http://docs.opencv.org/3.0-beta/doc/py_tutorials/py_imgproc/py_houghlines/py_houghlines.html
 
 type houghAccumulator struct {
-	bins     [][thetaCount]uint8
+	// one column per theta and one row per r bin
+	votes    *FloatImage
 	binWidth int
 }
 
@@ -36,7 +40,7 @@ func newHoughAccumulator(b *BinaryImage, binWidth int) *houghAccumulator {
 	bins := (maxR-minR)/binWidth + 1
 
 	return &houghAccumulator{
-		bins:     make([][thetaCount]uint8, bins),
+		votes:    NewFloatImage(image.Rect(0, 0, thetaCount, bins), 1),
 		binWidth: binWidth,
 	}
 }
@@ -55,7 +59,13 @@ func (acc *houghAccumulator) Inc(x, y, theta int) {
 		panic("Invalid theta")
 	}
 
-	acc.bins[bin][theta]++
+	acc.votes.Pix[acc.votes.PixOffset(theta, bin)]++
+}
+
+// Votes returns the votes of the accumulator as an image with one column per
+// theta and one row per r bin. Use AbsPolicy to visualize it.
+func (acc *houghAccumulator) Votes() *FloatImage {
+	return acc.votes
 }
 
 // HoughTransform performs a Hough Transform on the image and return an
@@ -92,11 +102,11 @@ func (b *BinaryImage) HoughTransform() *houghAccumulator {
 // DrawLines takes an (r, theta) accumulator as returned by HoughTransform and
 // draw the corresponding lines on the image.
 func (b *BinaryImage) DrawLines(acc *houghAccumulator) {
-	threshold := uint8(acc.binWidth * 5) // arbitrary
+	threshold := float32(acc.binWidth * 5) // arbitrary
 
-	for r, ts := range acc.bins {
-		for theta, n := range ts {
-			if n < threshold {
+	for r := 0; r < acc.votes.Rect.Max.Y; r++ {
+		for theta := 0; theta < thetaCount; theta++ {
+			if acc.votes.FloatAt(theta, r, 0) < threshold {
 				continue
 			}
 
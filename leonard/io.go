@@ -1,39 +1,152 @@
 package leonard
 
 import (
-	"fmt"
+	"bufio"
+	"errors"
 	"image"
+	"image/color"
+	"image/draw"
 	"image/gif"
 	"image/jpeg"
 	"image/png"
+	"io"
 	"os"
-	"path/filepath"
-	"strings"
+
+	"golang.org/x/image/tiff"
 )
 
+// EncodeOptions are the options passed to the encoders. A nil *EncodeOptions
+// means the defaults are used.
+type EncodeOptions struct {
+	JPEG *jpeg.Options
+	// GIF palettes are built with KMeansQuantizer unless a quantizer is given
+	GIF  *gif.Options
+	TIFF *tiff.Options
+	// The zero value is png.DefaultCompression
+	PNGCompression png.CompressionLevel
+}
+
+// PaletteQuantizer is a draw.Quantizer that always uses the same palette,
+// truncated to the number of colors asked by the encoder.
+type PaletteQuantizer color.Palette
+
+var _ draw.Quantizer = PaletteQuantizer{}
+
+// Quantize implements the draw.Quantizer interface
+func (q PaletteQuantizer) Quantize(p color.Palette, m image.Image) color.Palette {
+	n := cap(p) - len(p)
+	if n > len(q) {
+		n = len(q)
+	}
+	return append(p, q[:n]...)
+}
+
+// encodableFormat returns the format with the given name, or the one guessed
+// from the filename if the name is empty. An error is returned if the format
+// can't be encoded.
+func encodableFormat(format, filename string) (*Format, error) {
+	var f *Format
+	var err error
+
+	if format != "" {
+		f, err = LookupFormat(format)
+	} else if filename == "-" {
+		f, err = LookupFormat("png")
+	} else {
+		f, err = LookupFormatByFilename(filename)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if f.Encode == nil {
+		return nil, unsupportedFormat("%s images can't be encoded", f.Name)
+	}
+	return f, nil
+}
+
+// Encode writes an image to w in the given format; see LookupFormat.
+func Encode(w io.Writer, img image.Image, format string, opts *EncodeOptions) error {
+	f, err := encodableFormat(format, "")
+	if err != nil {
+		return err
+	}
+	return encode(w, img, f, opts)
+}
+
+func encode(w io.Writer, img image.Image, f *Format, opts *EncodeOptions) error {
+	if opts == nil {
+		opts = &EncodeOptions{}
+	}
+	return f.Encode(w, img, opts)
+}
+
+// Decode reads an image from r. Its format is detected from its first bytes.
+//
+// The returned error matches ErrUnsupportedFormat if the format is unknown and
+// ErrCorrupt if the image can't be decoded.
+func Decode(r io.Reader) (image.Image, error) {
+	br := bufio.NewReader(r)
+
+	header, err := br.Peek(maxMagicLength)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	f, err := DetectFormat(header)
+	if err != nil {
+		return nil, err
+	}
+
+	img, err := f.Decode(br)
+	if errors.Is(err, ErrCorrupt) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, &imageError{kind: ErrCorrupt, msg: f.Name, err: err}
+	}
+	return img, nil
+}
+
 // SaveImage saves an image to a file. The correct format is guessed from the
-// file extension. The default format for files without extension is PNG.
+// file extension. The default format for files without extension is PNG. If
+// the filename is "-" the image is written as PNG on os.Stdout.
 func SaveImage(img image.Image, filename string) error {
-	f, err := os.Create(filename)
+	return SaveImageAs(img, filename, "", nil)
+}
+
+// SaveImageAs saves an image to a file in the given format, using the given
+// encoder options. If the format is empty it's guessed from the filename; see
+// SaveImage. Nothing is written if the format isn't supported, and the file is
+// removed if the image can't be encoded.
+func SaveImageAs(img image.Image, filename, format string, opts *EncodeOptions) error {
+	f, err := encodableFormat(format, filename)
+	if err != nil {
+		return err
+	}
+
+	if filename == "-" {
+		return encode(os.Stdout, img, f, opts)
+	}
+
+	w, err := os.Create(filename)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
 
-	switch ext := strings.ToLower(filepath.Ext(filename)); ext {
-	case ".jpg", ".jpeg":
-		return jpeg.Encode(f, img, nil)
-	case "", ".png":
-		return png.Encode(f, img)
-	case ".gif":
-		return gif.Encode(f, img, nil)
-	default:
-		return fmt.Errorf("Unknown format: %s", ext)
+	err = encode(w, img, f, opts)
+	if closeErr := w.Close(); err == nil {
+		err = closeErr
 	}
+	if err != nil {
+		// Don't leave a truncated file that would look like a saved image
+		os.Remove(filename)
+	}
+	return err
 }
 
 // LoadImage loads an image from a file. If the filename is "-" the image is
-// read from os.Stdin.
+// read from os.Stdin. See Decode for the errors.
 func LoadImage(filename string) (image.Image, error) {
 	var f *os.File
 
@@ -47,7 +160,5 @@ func LoadImage(filename string) (image.Image, error) {
 		defer f.Close()
 	}
 
-	img, _, err := image.Decode(f)
-
-	return img, err
+	return Decode(f)
 }
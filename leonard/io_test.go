@@ -0,0 +1,212 @@
+package leonard
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// noise returns a w×h image of random colors
+func noise(w, h int) *image.NRGBA {
+	r := rand.New(rand.NewSource(1))
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for i := range img.Pix {
+		if i%4 == 3 {
+			img.Pix[i] = 0xff
+		} else {
+			img.Pix[i] = uint8(r.Intn(256))
+		}
+	}
+	return img
+}
+
+// threeColors returns an image with vertical bands of red, green and blue
+func threeColors() *image.NRGBA {
+	colors := []color.NRGBA{{0xff, 0, 0, 0xff}, {0, 0xff, 0, 0xff}, {0, 0, 0xff, 0xff}}
+	img := image.NewNRGBA(image.Rect(0, 0, 12, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 12; x++ {
+			img.SetNRGBA(x, y, colors[x/4])
+		}
+	}
+	return img
+}
+
+// encodeDecode encodes an image and decodes it back. It returns the decoded
+// image and the size of the encoded one.
+func encodeDecode(t *testing.T, img image.Image, format string, opts *EncodeOptions) (image.Image, int) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, img, format, opts); err != nil {
+		t.Fatalf("%s: %s", format, err)
+	}
+	size := buf.Len()
+	decoded, err := Decode(&buf)
+	if err != nil {
+		t.Fatalf("%s: %s", format, err)
+	}
+	return decoded, size
+}
+
+// meanError returns the mean absolute difference between the 8-bit channels
+// of two images of the same size.
+func meanError(a, b image.Image) float64 {
+	bounds := a.Bounds()
+	var sum float64
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			ca := color.NRGBAModel.Convert(a.At(x, y)).(color.NRGBA)
+			cb := color.NRGBAModel.Convert(b.At(x, y)).(color.NRGBA)
+			for _, d := range []int{
+				int(ca.R) - int(cb.R),
+				int(ca.G) - int(cb.G),
+				int(ca.B) - int(cb.B),
+			} {
+				if d < 0 {
+					d = -d
+				}
+				sum += float64(d)
+			}
+		}
+	}
+	return sum / float64(3*bounds.Dx()*bounds.Dy())
+}
+
+// distinctColors returns the number of distinct colors of an image
+func distinctColors(img image.Image) int {
+	colors := make(map[color.NRGBA]bool)
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			colors[color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)] = true
+		}
+	}
+	return len(colors)
+}
+
+func TestEncodeJPEGQuality(t *testing.T) {
+	img := noise(32, 32)
+
+	low, lowSize := encodeDecode(t, img, "jpeg", &EncodeOptions{JPEG: &jpeg.Options{Quality: 10}})
+	high, highSize := encodeDecode(t, img, "jpeg", &EncodeOptions{JPEG: &jpeg.Options{Quality: 95}})
+
+	if lowSize >= highSize {
+		t.Errorf("got %d bytes with a quality of 10 and %d with 95", lowSize, highSize)
+	}
+	if meanError(img, low) <= meanError(img, high) {
+		t.Errorf("got a mean error of %f with a quality of 10 and %f with 95", meanError(img, low), meanError(img, high))
+	}
+}
+
+func TestEncodePNGCompression(t *testing.T) {
+	img := threeColors()
+
+	var sizes []int
+	for _, level := range []png.CompressionLevel{png.NoCompression, png.DefaultCompression, png.BestCompression} {
+		decoded, size := encodeDecode(t, img, "png", &EncodeOptions{PNGCompression: level})
+		if e := meanError(img, decoded); e != 0 {
+			t.Errorf("compression %d: got a mean error of %f, want 0", level, e)
+		}
+		sizes = append(sizes, size)
+	}
+
+	if sizes[0] <= sizes[1] || sizes[2] > sizes[1] {
+		t.Errorf("got sizes %v for no, default and best compression", sizes)
+	}
+}
+
+func TestEncodeGIFColors(t *testing.T) {
+	img := threeColors()
+
+	// The default quantizer finds the colors of the image
+	decoded, _ := encodeDecode(t, img, "gif", nil)
+	if e := meanError(img, decoded); e != 0 {
+		t.Errorf("got a mean error of %f, want 0", e)
+	}
+
+	decoded, _ = encodeDecode(t, noise(16, 16), "gif", &EncodeOptions{GIF: &gif.Options{NumColors: 4}})
+	if n := distinctColors(decoded); n > 4 {
+		t.Errorf("got %d colors, want at most 4", n)
+	}
+}
+
+func TestEncodeGIFPaletteQuantizer(t *testing.T) {
+	black, white := color.RGBA{0, 0, 0, 0xff}, color.RGBA{0xff, 0xff, 0xff, 0xff}
+	q := PaletteQuantizer{black, white, color.RGBA{0xff, 0, 0, 0xff}}
+
+	decoded, _ := encodeDecode(t, noise(16, 16), "gif", &EncodeOptions{GIF: &gif.Options{NumColors: 2, Quantizer: q}})
+
+	p, ok := decoded.(*image.Paletted)
+	if !ok {
+		t.Fatalf("got a %T, want a paletted image", decoded)
+	}
+	if len(p.Palette) != 2 || p.Palette[0] != color.Color(black) || p.Palette[1] != color.Color(white) {
+		t.Errorf("got palette %v, want black and white", p.Palette)
+	}
+}
+
+func TestPaletteQuantizer(t *testing.T) {
+	q := PaletteQuantizer{color.Black, color.White}
+
+	if p := q.Quantize(make(color.Palette, 0, 256), nil); len(p) != 2 {
+		t.Errorf("got %d colors, want 2", len(p))
+	}
+	// the palette is truncated to the room left
+	if p := q.Quantize(append(make(color.Palette, 0, 2), color.Opaque), nil); len(p) != 2 || p[1] != color.Black {
+		t.Errorf("got %v, want opaque and black", p)
+	}
+}
+
+func TestEncodableFormat(t *testing.T) {
+	for _, tt := range []struct {
+		format, filename string
+		want             string
+	}{
+		{"", "a.jpg", "jpeg"},
+		{"", "-", "png"},
+		{"gif", "a.png", "gif"},
+		{"tiff", "-", "tiff"},
+	} {
+		f, err := encodableFormat(tt.format, tt.filename)
+		if err != nil || f.Name != tt.want {
+			t.Errorf("'%s', '%s': got %v (%v), want %s", tt.format, tt.filename, f, err, tt.want)
+		}
+	}
+
+	for _, tt := range [][2]string{{"webp", ""}, {"", "a.webp"}, {"xyz", ""}} {
+		if _, err := encodableFormat(tt[0], tt[1]); err == nil {
+			t.Errorf("'%s', '%s': expected an error", tt[0], tt[1])
+		}
+	}
+}
+
+func TestSaveImageAsError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "leonard")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// PNG can't encode empty images
+	filename := filepath.Join(dir, "empty.png")
+	if err := SaveImageAs(image.NewGray(image.Rectangle{}), filename, "", nil); err == nil {
+		t.Fatal("expected an error")
+	}
+	if _, err := os.Stat(filename); !os.IsNotExist(err) {
+		t.Errorf("got %v, want the file to be removed", err)
+	}
+
+	if err := SaveImageAs(threeColors(), filename, "", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadImage(filename); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,233 @@
+package leonard
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// Connectivity defines which pixels are neighbours
+type Connectivity int
+
+const (
+	// Connectivity4 only considers the pixels above, below, on the left and
+	// on the right as neighbours.
+	Connectivity4 Connectivity = 4
+	// Connectivity8 also considers the diagonal pixels as neighbours
+	Connectivity8 Connectivity = 8
+)
+
+func (c Connectivity) offsets() []offset {
+	switch c {
+	case Connectivity4:
+		return []offset{north, east, south, west}
+	case Connectivity8:
+		return clockwiseOffsets
+	default:
+		panic("Invalid connectivity")
+	}
+}
+
+// WatershedLine is the label of the pixels that separate the regions of a
+// watershed segmentation.
+const WatershedLine = -1
+
+// LabelImage is an image where each pixel has an integer label, e.g. the
+// region it belongs to. 0 means the pixel has no label.
+//
+// When seen as an image.Image, each label has its own color; unlabelled pixels
+// are black and watershed lines are white.
+type LabelImage struct {
+	// Labels holds the label of each pixel, row by row
+	Labels []int
+	Rect   image.Rectangle
+}
+
+var _ image.Image = &LabelImage{}
+
+// NewLabelImage returns a new LabelImage with the given bounds. No pixel is
+// labelled.
+func NewLabelImage(r image.Rectangle) *LabelImage {
+	return &LabelImage{
+		Labels: make([]int, r.Dx()*r.Dy()),
+		Rect:   r,
+	}
+}
+
+// ColorModel implements the image.Image interface
+func (l *LabelImage) ColorModel() color.Model {
+	return color.RGBA64Model
+}
+
+// Bounds implements the image.Image interface
+func (l *LabelImage) Bounds() image.Rectangle {
+	return l.Rect
+}
+
+// At implements the image.Image interface
+func (l *LabelImage) At(x, y int) color.Color {
+	return labelColor(l.Label(x, y))
+}
+
+// labelColor returns the color of a label. Consecutive labels get very
+// different hues.
+func labelColor(label int) color.RGBA64 {
+	switch {
+	case label == 0:
+		return color.RGBA64{0, 0, 0, 0xFFFF}
+	case label == WatershedLine:
+		return color.RGBA64{0xFFFF, 0xFFFF, 0xFFFF, 0xFFFF}
+	}
+	// golden angle
+	hue := math.Mod(float64(label)*137.508, 360)
+	return hsvToRGB(hue, 0.7, 0.95)
+}
+
+// index returns the index of the pixel at (x, y) in Labels
+func (l *LabelImage) index(x, y int) int {
+	return (y-l.Rect.Min.Y)*l.Rect.Dx() + (x - l.Rect.Min.X)
+}
+
+// Label returns the label of the pixel at (x, y)
+func (l *LabelImage) Label(x, y int) int {
+	if !(image.Point{x, y}.In(l.Rect)) {
+		return 0
+	}
+	return l.Labels[l.index(x, y)]
+}
+
+// SetLabel sets the label of the pixel at (x, y)
+func (l *LabelImage) SetLabel(x, y, label int) {
+	if !(image.Point{x, y}.In(l.Rect)) {
+		return
+	}
+	l.Labels[l.index(x, y)] = label
+}
+
+// MaxLabel returns the highest label of the image
+func (l *LabelImage) MaxLabel() int {
+	max := 0
+	for _, label := range l.Labels {
+		if label > max {
+			max = label
+		}
+	}
+	return max
+}
+
+// Overlay draws the labels over an image: labelled regions are tinted with
+// their color with the given opacity, between 0 and 1, and watershed lines are
+// drawn in white.
+func (l *LabelImage) Overlay(img image.Image, opacity float64) image.Image {
+	bounds := img.Bounds()
+	overlay := newColorImageLike(bounds, img)
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := img.At(x, y)
+
+			switch label := l.Label(x, y); label {
+			case 0:
+				overlay.Set(x, y, c)
+			case WatershedLine:
+				overlay.Set(x, y, labelColor(label))
+			default:
+				r, g, b, a := c.RGBA()
+				lc := labelColor(label)
+				mix := func(v uint32, lv uint16) uint16 {
+					return uint16((1-opacity)*float64(v) + opacity*float64(lv))
+				}
+				overlay.Set(x, y, color.RGBA64{mix(r, lc.R), mix(g, lc.G), mix(b, lc.B), mix(a, lc.A)})
+			}
+		}
+	}
+
+	return overlay
+}
+
+// Boundaries draws the boundaries between the labelled regions over an image
+// with the given color.
+func (l *LabelImage) Boundaries(img image.Image, c color.Color) image.Image {
+	bounds := img.Bounds()
+	out := newColorImageLike(bounds, img)
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			label := l.Label(x, y)
+			// Only look on the right and below to get 1-pixel wide boundaries
+			if (x+1 < bounds.Max.X && l.Label(x+1, y) != label) ||
+				(y+1 < bounds.Max.Y && l.Label(x, y+1) != label) {
+				out.Set(x, y, c)
+			} else {
+				out.Set(x, y, img.At(x, y))
+			}
+		}
+	}
+
+	return out
+}
+
+// MeanColors returns an image where each labelled region is filled with the
+// mean color of its pixels in img. Unlabelled pixels and watershed lines are
+// kept as they are.
+func (l *LabelImage) MeanColors(img image.Image) image.Image {
+	bounds := img.Bounds()
+	regions := make(map[int]*Region)
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			label := l.Label(x, y)
+			if label <= 0 {
+				continue
+			}
+			r, ok := regions[label]
+			if !ok {
+				r = &Region{}
+				regions[label] = r
+			}
+			r.add(img.At(x, y))
+		}
+	}
+
+	out := newImageLike(bounds, img)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if r, ok := regions[l.Label(x, y)]; ok {
+				out.Set(x, y, r.Mean())
+			} else {
+				out.Set(x, y, img.At(x, y))
+			}
+		}
+	}
+
+	return out
+}
+
+// ConnectedComponents labels the connected groups of truthy pixels of the
+// image from 1, from top to bottom and left to right.
+func (b *BinaryImage) ConnectedComponents(connectivity Connectivity) *LabelImage {
+	offsets := connectivity.offsets()
+	labels := NewLabelImage(b.Bounds())
+
+	next := 1
+	for _, p := range b.Points() {
+		if labels.Label(p.X, p.Y) != 0 {
+			continue
+		}
+
+		labels.SetLabel(p.X, p.Y, next)
+		for queue := []image.Point{p}; len(queue) > 0; queue = queue[1:] {
+			q := queue[0]
+			for _, o := range offsets {
+				x, y := o.apply(q.X, q.Y)
+				if b.Get(x, y) && labels.Label(x, y) == 0 {
+					labels.SetLabel(x, y, next)
+					queue = append(queue, image.Point{x, y})
+				}
+			}
+		}
+		next++
+	}
+
+	return labels
+}
@@ -0,0 +1,41 @@
+package leonard
+
+import (
+	"image"
+	"image/color"
+)
+
+// newGrayImage returns a w×h grayscale image whose pixels are given by fn
+func newGrayImage(w, h int, fn func(x, y int) uint8) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetGray(x, y, color.Gray{fn(x, y)})
+		}
+	}
+	return img
+}
+
+// uniformGray returns a w×h image of a single gray level
+func uniformGray(w, h int, v uint8) *image.Gray {
+	return newGrayImage(w, h, func(x, y int) uint8 { return v })
+}
+
+// gray16At returns the 16-bit luminance of a pixel
+func gray16At(img image.Image, x, y int) uint16 {
+	return color.Gray16Model.Convert(img.At(x, y)).(color.Gray16).Y
+}
+
+// newBinaryImage returns a binary image drawn with strings, one per row,
+// where '#' is white.
+func newBinaryImage(rows ...string) *BinaryImage {
+	b := NewEmptyBinaryImage(len(rows), len(rows[0]))
+	for y, row := range rows {
+		for x, c := range row {
+			if c == '#' {
+				b.Set(x, y, true)
+			}
+		}
+	}
+	return b
+}
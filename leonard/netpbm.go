@@ -0,0 +1,388 @@
+package leonard
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"io"
+)
+
+// Netpbm formats: PBM (black & white), PGM (grayscale) and PPM (RGB). Each
+// one has a "plain" (ASCII) variant and a "raw" (binary) one.
+//
+// Specs:
+//     http://netpbm.sourceforge.net/doc/pbm.html
+//     http://netpbm.sourceforge.net/doc/pgm.html
+//     http://netpbm.sourceforge.net/doc/ppm.html
+
+func init() {
+	image.RegisterFormat("pbm", "P1", DecodeNetpbm, DecodeNetpbmConfig)
+	image.RegisterFormat("pbm", "P4", DecodeNetpbm, DecodeNetpbmConfig)
+	image.RegisterFormat("pgm", "P2", DecodeNetpbm, DecodeNetpbmConfig)
+	image.RegisterFormat("pgm", "P5", DecodeNetpbm, DecodeNetpbmConfig)
+	image.RegisterFormat("ppm", "P3", DecodeNetpbm, DecodeNetpbmConfig)
+	image.RegisterFormat("ppm", "P6", DecodeNetpbm, DecodeNetpbmConfig)
+}
+
+var errNetpbmHeader = errors.New("netpbm: invalid header")
+
+type netpbmHeader struct {
+	magic         string
+	width, height int
+	// maximum value of a sample; 1 for PBM
+	maxval int
+}
+
+func (h netpbmHeader) plain() bool {
+	return h.magic == "P1" || h.magic == "P2" || h.magic == "P3"
+}
+
+// netpbmReader reads the whitespace-separated tokens of a Netpbm file
+type netpbmReader struct {
+	*bufio.Reader
+}
+
+func (r netpbmReader) skipSpaces() error {
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return err
+		}
+		switch c {
+		case ' ', '\t', '\n', '\r', '\v', '\f':
+		case '#':
+			// comments go until the end of the line
+			if _, err := r.ReadString('\n'); err != nil {
+				return err
+			}
+		default:
+			return r.UnreadByte()
+		}
+	}
+}
+
+func (r netpbmReader) token() (string, error) {
+	if err := r.skipSpaces(); err != nil {
+		return "", err
+	}
+
+	var tok []byte
+	for {
+		c, err := r.ReadByte()
+		if err == io.EOF && len(tok) > 0 {
+			return string(tok), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f' || c == '#' {
+			return string(tok), r.UnreadByte()
+		}
+		tok = append(tok, c)
+	}
+}
+
+func (r netpbmReader) int() (int, error) {
+	tok, err := r.token()
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, c := range []byte(tok) {
+		if c < '0' || c > '9' || n > 1<<24 {
+			return 0, fmt.Errorf("netpbm: invalid number '%s'", tok)
+		}
+		n = n*10 + int(c-'0')
+	}
+	return n, nil
+}
+
+func (r netpbmReader) header() (h netpbmHeader, err error) {
+	magic := make([]byte, 2)
+	if _, err = io.ReadFull(r, magic); err != nil {
+		return
+	}
+	h.magic = string(magic)
+	if h.magic[0] != 'P' || h.magic[1] < '1' || h.magic[1] > '6' {
+		return h, errNetpbmHeader
+	}
+
+	if h.width, err = r.int(); err != nil {
+		return
+	}
+	if h.height, err = r.int(); err != nil {
+		return
+	}
+
+	if h.magic == "P1" || h.magic == "P4" {
+		h.maxval = 1
+	} else if h.maxval, err = r.int(); err != nil {
+		return
+	}
+
+	if h.width <= 0 || h.height <= 0 || h.maxval <= 0 || h.maxval > 0xFFFF {
+		return h, errNetpbmHeader
+	}
+
+	if !h.plain() {
+		// Exactly one whitespace character after the header
+		_, err = r.ReadByte()
+	}
+	return
+}
+
+// DecodeNetpbmConfig returns the color model and dimensions of a Netpbm image
+// without decoding it.
+func DecodeNetpbmConfig(r io.Reader) (image.Config, error) {
+	h, err := netpbmReader{bufio.NewReader(r)}.header()
+	if err != nil {
+		return image.Config{}, err
+	}
+
+	var model color.Model
+	switch h.magic {
+	case "P1", "P4":
+		model = color.GrayModel
+	case "P2", "P5":
+		model = color.GrayModel
+		if h.maxval > 0xFF {
+			model = color.Gray16Model
+		}
+	default:
+		model = color.RGBAModel
+		if h.maxval > 0xFF {
+			model = color.RGBA64Model
+		}
+	}
+
+	return image.Config{ColorModel: model, Width: h.width, Height: h.height}, nil
+}
+
+// DecodeNetpbm decodes a PBM, PGM or PPM image. PBM images are returned as
+// *BinaryImage values; PGM and PPM ones with a maximum value higher than 255
+// are returned as 16-bit images.
+func DecodeNetpbm(r io.Reader) (image.Image, error) {
+	nr := netpbmReader{bufio.NewReader(r)}
+	h, err := nr.header()
+	if err != nil {
+		return nil, err
+	}
+
+	if h.magic == "P1" || h.magic == "P4" {
+		return decodePBM(nr, h)
+	}
+
+	// read reads the next sample
+	var read func() (int, error)
+
+	if h.plain() {
+		read = nr.int
+	} else if h.maxval > 0xFF {
+		buf := make([]byte, 2)
+		read = func() (int, error) {
+			_, err := io.ReadFull(nr, buf)
+			return int(buf[0])<<8 | int(buf[1]), err
+		}
+	} else {
+		read = func() (int, error) {
+			c, err := nr.ReadByte()
+			return int(c), err
+		}
+	}
+
+	channels := 1
+	if h.magic == "P3" || h.magic == "P6" {
+		channels = 3
+	}
+
+	// The samples are read before allocating the image so that a header with
+	// huge dimensions can't make us allocate much more memory than the size
+	// of the input.
+	count := h.width * h.height * channels
+	var samples []uint16
+	for len(samples) < count {
+		n, err := read()
+		if err != nil {
+			return nil, err
+		}
+		if n > h.maxval {
+			return nil, &imageError{
+				kind: ErrCorrupt,
+				msg:  fmt.Sprintf("netpbm: value %d higher than %d", n, h.maxval),
+			}
+		}
+		// scale it to 0-0xFFFF
+		samples = append(samples, uint16(n*0xFFFF/h.maxval))
+	}
+
+	bounds := image.Rect(0, 0, h.width, h.height)
+
+	if channels == 1 {
+		if h.maxval > 0xFF {
+			img := image.NewGray16(bounds)
+			for i, v := range samples {
+				img.Pix[2*i], img.Pix[2*i+1] = uint8(v>>8), uint8(v)
+			}
+			return img, nil
+		}
+
+		img := image.NewGray(bounds)
+		for i, v := range samples {
+			img.Pix[i] = uint8(v >> 8)
+		}
+		return img, nil
+	}
+
+	var img draw.Image
+	if h.maxval > 0xFF {
+		img = image.NewRGBA64(bounds)
+	} else {
+		img = image.NewRGBA(bounds)
+	}
+
+	for i := 0; i < len(samples); i += 3 {
+		x, y := (i/3)%h.width, (i/3)/h.width
+		img.Set(x, y, color.RGBA64{samples[i], samples[i+1], samples[i+2], 0xFFFF})
+	}
+	return img, nil
+}
+
+func decodePBM(r netpbmReader, h netpbmHeader) (*BinaryImage, error) {
+	b := NewEmptyBinaryImage(h.height, h.width)
+
+	// In PBM files 1 is black and 0 is white
+	if h.plain() {
+		for y := 0; y < h.height; y++ {
+			for x := 0; x < h.width; x++ {
+				// Plain PBM values may not be separated by whitespaces
+				if err := r.skipSpaces(); err != nil {
+					return nil, err
+				}
+				c, err := r.ReadByte()
+				if err != nil {
+					return nil, err
+				}
+				if c != '0' && c != '1' {
+					return nil, fmt.Errorf("netpbm: invalid PBM value '%c'", c)
+				}
+				if c == '0' {
+					b.Set(x, y, true)
+				}
+			}
+		}
+		return b, nil
+	}
+
+	// Raw PBM: 8 pixels per byte, each row starts on a new byte
+	row := make([]byte, (h.width+7)/8)
+	for y := 0; y < h.height; y++ {
+		if _, err := io.ReadFull(r, row); err != nil {
+			return nil, err
+		}
+		for x := 0; x < h.width; x++ {
+			if row[x/8]&(0x80>>uint(x%8)) == 0 {
+				b.Set(x, y, true)
+			}
+		}
+	}
+	return b, nil
+}
+
+// EncodePBM writes an image in the raw PBM format. Pixels whose luminance is at
+// least half the maximum one are white.
+func EncodePBM(w io.Writer, img image.Image) error {
+	bounds := img.Bounds()
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintf(bw, "P4\n%d %d\n", bounds.Dx(), bounds.Dy())
+
+	row := make([]byte, (bounds.Dx()+7)/8)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for i := range row {
+			row[i] = 0
+		}
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if luminance(img.At(x, y)) < 0x8000 {
+				// black
+				i := x - bounds.Min.X
+				row[i/8] |= 0x80 >> uint(i%8)
+			}
+		}
+		bw.Write(row)
+	}
+
+	return bw.Flush()
+}
+
+// EncodePGM writes an image in the raw PGM format, using the luminance of its
+// pixels. 16-bit images are written with 16-bit samples.
+func EncodePGM(w io.Writer, img image.Image) error {
+	bounds := img.Bounds()
+	bw := bufio.NewWriter(w)
+
+	wide := is16Bit(img)
+	maxval := 0xFF
+	if wide {
+		maxval = 0xFFFF
+	}
+
+	fmt.Fprintf(bw, "P5\n%d %d\n%d\n", bounds.Dx(), bounds.Dy(), maxval)
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			v := uint16(luminance(img.At(x, y)))
+			bw.WriteByte(uint8(v >> 8))
+			if wide {
+				bw.WriteByte(uint8(v))
+			}
+		}
+	}
+
+	return bw.Flush()
+}
+
+// EncodePPM writes an image in the raw PPM format. 16-bit images are written
+// with 16-bit samples. PPM doesn't support transparency.
+func EncodePPM(w io.Writer, img image.Image) error {
+	bounds := img.Bounds()
+	bw := bufio.NewWriter(w)
+
+	wide := is16Bit(img)
+	maxval := 0xFF
+	if wide {
+		maxval = 0xFFFF
+	}
+
+	fmt.Fprintf(bw, "P6\n%d %d\n%d\n", bounds.Dx(), bounds.Dy(), maxval)
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, _ := img.At(x, y).RGBA()
+			for _, v := range []uint32{r, g, b} {
+				bw.WriteByte(uint8(v >> 8))
+				if wide {
+					bw.WriteByte(uint8(v))
+				}
+			}
+		}
+	}
+
+	return bw.Flush()
+}
+
+// EncodeNetpbm writes an image in the Netpbm format that best matches it: PBM
+// for binary images, PGM for grayscale ones and PPM for the others.
+func EncodeNetpbm(w io.Writer, img image.Image) error {
+	switch img.ColorModel() {
+	case color.GrayModel, color.Gray16Model:
+		if _, ok := img.(*BinaryImage); ok {
+			return EncodePBM(w, img)
+		}
+		return EncodePGM(w, img)
+	default:
+		return EncodePPM(w, img)
+	}
+}
@@ -0,0 +1,129 @@
+package leonard
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func decodeNetpbmString(s string) (image.Image, error) {
+	return DecodeNetpbm(strings.NewReader(s))
+}
+
+func TestDecodePlainPGM(t *testing.T) {
+	img, err := decodeNetpbmString("P2\n# comment\n3 2\n4\n0 1 2\n3 4 4\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gray, ok := img.(*image.Gray)
+	if !ok {
+		t.Fatalf("got a %T, want an *image.Gray", img)
+	}
+	want := []uint8{0, 63, 127, 191, 255, 255}
+	if !bytes.Equal(gray.Pix, want) {
+		t.Errorf("got %v, want %v", gray.Pix, want)
+	}
+}
+
+func TestDecodeRawPPM16(t *testing.T) {
+	img, err := decodeNetpbmString("P6 1 1 1000\n\x03\xe8\x01\xf4\x00\x00")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := color.RGBA64Model.Convert(img.At(0, 0)).(color.RGBA64)
+	want := color.RGBA64{0xFFFF, 0x7FFF, 0, 0xFFFF}
+	if got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestDecodePBM(t *testing.T) {
+	for _, s := range []string{
+		// plain PBM values don't need to be separated
+		"P1\n3 2\n010\n1 0 1\n",
+		"P4\n3 2\n\x40\xa0",
+	} {
+		img, err := decodeNetpbmString(s)
+		if err != nil {
+			t.Errorf("%q: %s", s, err)
+			continue
+		}
+
+		for i, black := range []bool{false, true, false, true, false, true} {
+			x, y := i%3, i/3
+			if got := gray16At(img, x, y) == 0; got != black {
+				t.Errorf("%q: got black=%v at (%d, %d)", s, got, x, y)
+			}
+		}
+	}
+}
+
+func TestDecodeNetpbmValueHigherThanMaxval(t *testing.T) {
+	for _, s := range []string{
+		"P2 2 1 100 50 200\n",
+		"P5 2 1 100\n\x32\xc8",
+		"P6 1 1 1000\n\x03\xe9\x00\x00\x00\x00",
+	} {
+		if _, err := decodeNetpbmString(s); !errors.Is(err, ErrCorrupt) {
+			t.Errorf("%q: got %v, want an ErrCorrupt", s, err)
+		}
+	}
+}
+
+func TestDecodeNetpbmErrors(t *testing.T) {
+	for _, s := range []string{
+		"P7 1 1 255\n\x00",
+		"P5 0 1 255\n",
+		"P5 1 1 0\n\x00",
+		"P5 1 1 70000\n\x00\x00",
+		"P2 2 2 255\n1 2 3",
+		"P1 2 1 0 2",
+		// a huge image must fail on the missing data rather than on its
+		// allocation
+		"P6 16000000 16000000 255\n\x00\x00\x00",
+	} {
+		if _, err := decodeNetpbmString(s); err == nil {
+			t.Errorf("%q: expected an error", s)
+		}
+	}
+}
+
+func TestDecodeNetpbmCorruptThroughDecode(t *testing.T) {
+	_, err := Decode(strings.NewReader("P5 2 1 100\n\x32\xc8"))
+	if !errors.Is(err, ErrCorrupt) {
+		t.Fatalf("got %v, want an ErrCorrupt", err)
+	}
+	if strings.Count(err.Error(), ErrCorrupt.Error()) != 1 {
+		t.Errorf("the error is wrapped twice: %s", err)
+	}
+}
+
+func TestEncodePPMRoundTrip(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	img.Set(0, 0, color.RGBA{255, 0, 0, 255})
+	img.Set(1, 0, color.RGBA{0, 255, 0, 255})
+	img.Set(0, 1, color.RGBA{0, 0, 255, 255})
+	img.Set(1, 1, color.RGBA{10, 20, 30, 255})
+
+	var buf bytes.Buffer
+	if err := EncodePPM(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeNetpbm(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 2; x++ {
+			if got, want := decoded.At(x, y), img.At(x, y); got != want {
+				t.Errorf("got %v at (%d, %d), want %v", got, x, y, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,345 @@
+package leonard
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"sort"
+)
+
+// maxQuantizeSamples is the maximum number of pixels looked at to build a
+// palette. Bigger images are sampled on a regular grid.
+const maxQuantizeSamples = 1 << 16
+
+// defaultKMeansIterations is the default number of iterations of
+// KMeansQuantizer
+const defaultKMeansIterations = 10
+
+// weightedColor is a color with the number of sampled pixels that have it.
+// Channels are alpha-premultiplied, in the 0-0xFFFF range.
+type weightedColor struct {
+	c      [4]float64
+	weight float64
+}
+
+// colorHistogram returns the distinct colors of an image, with 8 bits per
+// channel, and their number of occurrences.
+func colorHistogram(img image.Image) []weightedColor {
+	bounds := img.Bounds()
+
+	step := 1
+	if n := bounds.Dx() * bounds.Dy(); n > maxQuantizeSamples {
+		step = int(math.Ceil(math.Sqrt(float64(n) / maxQuantizeSamples)))
+	}
+
+	counts := make(map[color.RGBA]int)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += step {
+		for x := bounds.Min.X; x < bounds.Max.X; x += step {
+			c := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+			counts[c]++
+		}
+	}
+
+	colors := make([]weightedColor, 0, len(counts))
+	for c, n := range counts {
+		colors = append(colors, weightedColor{
+			c:      [4]float64{float64(c.R) * 0x101, float64(c.G) * 0x101, float64(c.B) * 0x101, float64(c.A) * 0x101},
+			weight: float64(n),
+		})
+	}
+
+	// Map iteration is random; sort the colors so that the palettes are
+	// always the same for a given image.
+	sort.Slice(colors, func(i, j int) bool {
+		for k := 0; k < 4; k++ {
+			if colors[i].c[k] != colors[j].c[k] {
+				return colors[i].c[k] < colors[j].c[k]
+			}
+		}
+		return false
+	})
+
+	return colors
+}
+
+// meanColor returns the weighted mean of some colors
+func meanColor(colors []weightedColor) [4]float64 {
+	var sum [4]float64
+	total := 0.0
+	for _, c := range colors {
+		for k := range sum {
+			sum[k] += c.c[k] * c.weight
+		}
+		total += c.weight
+	}
+	for k := range sum {
+		sum[k] /= total
+	}
+	return sum
+}
+
+func toRGBA(c [4]float64) color.RGBA {
+	return color.RGBA{
+		uint8(c[0]/0x101 + 0.5),
+		uint8(c[1]/0x101 + 0.5),
+		uint8(c[2]/0x101 + 0.5),
+		uint8(c[3]/0x101 + 0.5),
+	}
+}
+
+// medianCut splits the colors in at most n boxes and returns the mean of each
+// box.
+func medianCut(colors []weightedColor, n int) [][4]float64 {
+	// Heckbert, "Color image quantization for frame buffer display" (1982)
+	if len(colors) == 0 || n <= 0 {
+		return nil
+	}
+
+	boxes := [][]weightedColor{colors}
+
+	// widest returns the channel along which a box is the widest and its
+	// range
+	widest := func(box []weightedColor) (int, float64) {
+		channel, width := 0, 0.0
+		for k := 0; k < 4; k++ {
+			min, max := box[0].c[k], box[0].c[k]
+			for _, c := range box {
+				min = math.Min(min, c.c[k])
+				max = math.Max(max, c.c[k])
+			}
+			if max-min > width {
+				channel, width = k, max-min
+			}
+		}
+		return channel, width
+	}
+
+	for len(boxes) < n {
+		// Split the widest box
+		best, channel, width := -1, 0, 0.0
+		for i, box := range boxes {
+			if len(box) < 2 {
+				continue
+			}
+			if k, w := widest(box); w > width {
+				best, channel, width = i, k, w
+			}
+		}
+		if best < 0 {
+			// all boxes have a single color
+			break
+		}
+
+		box := boxes[best]
+		sort.SliceStable(box, func(i, j int) bool { return box[i].c[channel] < box[j].c[channel] })
+
+		// Cut at the weighted median
+		total := 0.0
+		for _, c := range box {
+			total += c.weight
+		}
+		cut, acc := 1, box[0].weight
+		for cut < len(box)-1 && acc+box[cut].weight <= total/2 {
+			acc += box[cut].weight
+			cut++
+		}
+
+		boxes[best] = box[:cut]
+		boxes = append(boxes, box[cut:])
+	}
+
+	means := make([][4]float64, len(boxes))
+	for i, box := range boxes {
+		means[i] = meanColor(box)
+	}
+	return means
+}
+
+func colorDistance2(a, b [4]float64) float64 {
+	d := 0.0
+	for k := range a {
+		d += (a[k] - b[k]) * (a[k] - b[k])
+	}
+	return d
+}
+
+// kMeans refines the given centers with Lloyd's algorithm. It returns the
+// centers and the total weight of the colors assigned to each one.
+func kMeans(colors []weightedColor, centers [][4]float64, iterations int) ([][4]float64, []float64) {
+	assignments := make([]int, len(colors))
+	for i := range assignments {
+		assignments[i] = -1
+	}
+	weights := make([]float64, len(centers))
+
+	for iter := 0; iter < iterations; iter++ {
+		changed := false
+		for i, c := range colors {
+			best, bestDistance := 0, math.Inf(1)
+			for k, center := range centers {
+				if d := colorDistance2(c.c, center); d < bestDistance {
+					best, bestDistance = k, d
+				}
+			}
+			if assignments[i] != best {
+				assignments[i] = best
+				changed = true
+			}
+		}
+
+		sums := make([][4]float64, len(centers))
+		for k := range weights {
+			weights[k] = 0
+		}
+		for i, c := range colors {
+			k := assignments[i]
+			for ch := range sums[k] {
+				sums[k][ch] += c.c[ch] * c.weight
+			}
+			weights[k] += c.weight
+		}
+		for k := range centers {
+			// Empty clusters keep their center
+			if weights[k] > 0 {
+				for ch := range centers[k] {
+					centers[k][ch] = sums[k][ch] / weights[k]
+				}
+			}
+		}
+
+		if !changed {
+			break
+		}
+	}
+
+	return centers, weights
+}
+
+// MedianCutQuantizer is a draw.Quantizer that builds palettes with the median
+// cut algorithm: the colors of the image are recursively split in two groups
+// of the same size along their widest channel.
+type MedianCutQuantizer struct{}
+
+var _ draw.Quantizer = MedianCutQuantizer{}
+
+// Quantize implements the draw.Quantizer interface
+func (MedianCutQuantizer) Quantize(p color.Palette, m image.Image) color.Palette {
+	for _, c := range medianCut(colorHistogram(m), cap(p)-len(p)) {
+		p = append(p, toRGBA(c))
+	}
+	return p
+}
+
+// KMeansQuantizer is a draw.Quantizer that builds palettes with the k-means
+// clustering of the colors of the image. It's slower than MedianCutQuantizer
+// but gives palettes closer to the image. Clusters are initialized with the
+// median cut, so the palette is always the same for a given image.
+type KMeansQuantizer struct {
+	// Iterations is the maximum number of iterations; the default is 10
+	Iterations int
+}
+
+var _ draw.Quantizer = KMeansQuantizer{}
+
+// Quantize implements the draw.Quantizer interface
+func (q KMeansQuantizer) Quantize(p color.Palette, m image.Image) color.Palette {
+	centers, _ := q.cluster(colorHistogram(m), cap(p)-len(p))
+	for _, c := range centers {
+		p = append(p, toRGBA(c))
+	}
+	return p
+}
+
+// cluster returns at most n centers for the colors and the total weight of
+// the colors assigned to each one. There's none if n isn't positive or if
+// there's no color.
+func (q KMeansQuantizer) cluster(colors []weightedColor, n int) ([][4]float64, []float64) {
+	if len(colors) == 0 || n <= 0 {
+		return nil, nil
+	}
+
+	iterations := q.Iterations
+	if iterations <= 0 {
+		iterations = defaultKMeansIterations
+	}
+	return kMeans(colors, medianCut(colors, n), iterations)
+}
+
+// QuantizeOptions are the options of Quantize. A nil *QuantizeOptions means
+// the defaults are used.
+type QuantizeOptions struct {
+	// The default is KMeansQuantizer
+	Quantizer draw.Quantizer
+	// Dither spreads the quantization errors on the neighbouring pixels with
+	// Floyd-Steinberg's algorithm.
+	Dither bool
+}
+
+// Quantize reduces the number of colors of an image. The palette of the
+// returned image has at most the given number of colors.
+func Quantize(img image.Image, colors int, opts *QuantizeOptions) *image.Paletted {
+	if colors < 1 || colors > 256 {
+		panic("Quantize: the number of colors must be between 1 and 256")
+	}
+	if opts == nil {
+		opts = &QuantizeOptions{}
+	}
+
+	quantizer := opts.Quantizer
+	if quantizer == nil {
+		quantizer = KMeansQuantizer{}
+	}
+
+	bounds := img.Bounds()
+	palette := quantizer.Quantize(make(color.Palette, 0, colors), img)
+	paletted := image.NewPaletted(bounds, palette)
+
+	var drawer draw.Drawer = draw.Src
+	if opts.Dither {
+		drawer = draw.FloydSteinberg
+	}
+	drawer.Draw(paletted, bounds, img, bounds.Min)
+
+	return paletted
+}
+
+// DominantColor is a color of an image along with the fraction of the image
+// it covers.
+type DominantColor struct {
+	Color    color.NRGBA
+	Fraction float64
+}
+
+// DominantColors returns the n dominant colors of an image, most frequent
+// first. There can be less than n colors if the image has less distinct ones,
+// and none if it's empty or if n isn't positive.
+func DominantColors(img image.Image, n int) []DominantColor {
+	colors := colorHistogram(img)
+	if len(colors) == 0 || n <= 0 {
+		return nil
+	}
+
+	centers, weights := KMeansQuantizer{}.cluster(colors, n)
+
+	total := 0.0
+	for _, c := range colors {
+		total += c.weight
+	}
+
+	var dominant []DominantColor
+	for k, c := range centers {
+		if weights[k] == 0 {
+			continue
+		}
+		dominant = append(dominant, DominantColor{
+			Color:    color.NRGBAModel.Convert(toRGBA(c)).(color.NRGBA),
+			Fraction: weights[k] / total,
+		})
+	}
+
+	sort.SliceStable(dominant, func(i, j int) bool {
+		return dominant[i].Fraction > dominant[j].Fraction
+	})
+	return dominant
+}
@@ -0,0 +1,126 @@
+package leonard
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+// fourColors returns a 10×10 image with 4 colors covering 40%, 30%, 20% and
+// 10% of it.
+func fourColors() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	for y := 0; y < 10; y++ {
+		for x := 0; x < 10; x++ {
+			var c color.RGBA
+			switch {
+			case y < 4:
+				c = color.RGBA{255, 0, 0, 255}
+			case y < 7:
+				c = color.RGBA{0, 255, 0, 255}
+			case y < 9:
+				c = color.RGBA{0, 0, 255, 255}
+			default:
+				c = color.RGBA{255, 255, 255, 255}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestDominantColors(t *testing.T) {
+	want := []DominantColor{
+		{color.NRGBA{255, 0, 0, 255}, 0.4},
+		{color.NRGBA{0, 255, 0, 255}, 0.3},
+		{color.NRGBA{0, 0, 255, 255}, 0.2},
+		{color.NRGBA{255, 255, 255, 255}, 0.1},
+	}
+
+	got := DominantColors(fourColors(), 4)
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i].Color != want[i].Color || math.Abs(got[i].Fraction-want[i].Fraction) > 1e-9 {
+			t.Errorf("got %v, want %v", got[i], want[i])
+		}
+	}
+}
+
+func TestDominantColorsFewerColors(t *testing.T) {
+	got := DominantColors(uniformGray(4, 4, 100), 5)
+	if len(got) != 1 || got[0].Fraction != 1 {
+		t.Errorf("got %v, want a single color", got)
+	}
+}
+
+func TestDominantColorsEmpty(t *testing.T) {
+	if got := DominantColors(fourColors(), 0); got != nil {
+		t.Errorf("n=0: got %v, want nil", got)
+	}
+	if got := DominantColors(fourColors(), -1); got != nil {
+		t.Errorf("n=-1: got %v, want nil", got)
+	}
+	if got := DominantColors(image.NewRGBA(image.Rectangle{}), 3); got != nil {
+		t.Errorf("empty image: got %v, want nil", got)
+	}
+}
+
+func TestQuantizers(t *testing.T) {
+	for name, q := range map[string]interface {
+		Quantize(color.Palette, image.Image) color.Palette
+	}{
+		"median cut": MedianCutQuantizer{},
+		"k-means":    KMeansQuantizer{},
+	} {
+		p := q.Quantize(make(color.Palette, 0, 4), fourColors())
+		if len(p) != 4 {
+			t.Errorf("%s: got %d colors, want 4", name, len(p))
+		}
+
+		// existing colors are kept
+		p = q.Quantize(color.Palette{color.Black}, fourColors())
+		if len(p) != 1 || p[0] != color.Black {
+			t.Errorf("%s: got %v, want just black", name, p)
+		}
+
+		if p := q.Quantize(make(color.Palette, 0, 4), image.NewRGBA(image.Rectangle{})); len(p) != 0 {
+			t.Errorf("%s: got %v for an empty image", name, p)
+		}
+	}
+}
+
+func TestQuantize(t *testing.T) {
+	img := fourColors()
+
+	for _, dither := range []bool{false, true} {
+		paletted := Quantize(img, 4, &QuantizeOptions{Dither: dither})
+		for y := 0; y < 10; y++ {
+			for x := 0; x < 10; x++ {
+				got := color.RGBAModel.Convert(paletted.At(x, y))
+				if want := img.At(x, y); got != want {
+					t.Fatalf("dither=%v: got %v at (%d, %d), want %v", dither, got, x, y, want)
+				}
+			}
+		}
+	}
+
+	if got := len(Quantize(img, 2, nil).Palette); got != 2 {
+		t.Errorf("got %d colors, want 2", got)
+	}
+}
+
+func TestQuantizeInvalidColors(t *testing.T) {
+	for _, n := range []int{0, 257} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%d colors: expected a panic", n)
+				}
+			}()
+			Quantize(fourColors(), n, nil)
+		}()
+	}
+}
@@ -0,0 +1,481 @@
+package leonard
+
+import (
+	"image"
+	"math"
+	"math/rand"
+)
+
+const (
+	defaultRANSACThreshold  = 2.0
+	defaultRANSACIterations = 1000
+	defaultRANSACConfidence = 0.99
+)
+
+// RANSACModel is a model that can be fitted with RANSAC on a set of data
+// points, e.g. pixels or pairs of matched points, that it holds. Points are
+// referred to by their index, from 0 to Len()-1.
+type RANSACModel interface {
+	// SampleSize returns the minimal number of points needed to fit the model
+	SampleSize() int
+	// Len returns the number of points
+	Len() int
+	// Fit fits the model on the points with the given indices. With more
+	// points than the sample size it's a least squares fit. It returns false
+	// if the points are degenerate, e.g. if they're all the same.
+	Fit(indices []int) bool
+	// Residual returns the distance between a point and the fitted model
+	Residual(i int) float64
+}
+
+// RANSACOptions are the options of RANSAC. A nil *RANSACOptions means the
+// defaults are used.
+type RANSACOptions struct {
+	// Threshold is the maximal residual of the inliers; the default is 2
+	// (pixels).
+	Threshold float64
+	// Iterations is the maximal number of random samples; the default is
+	// 1000.
+	Iterations int
+	// Confidence stops the search early once the probability that one of the
+	// samples only had inliers is at least this value; the default is 0.99.
+	Confidence float64
+	// Seed is the seed of the random generator. The same seed gives the same
+	// results.
+	Seed int64
+}
+
+// RANSAC fits a model on points with outliers: it fits the model on random
+// samples of points and keeps the one that has the most inliers, i.e. the
+// points close enough to the model. The model is then refitted on all the
+// inliers.
+//
+// It returns the indices of the inliers, in increasing order, and leaves the
+// model fitted on them. It returns nil if no model could be fitted, e.g.
+// because there are less points than the sample size.
+func RANSAC(model RANSACModel, opts *RANSACOptions) []int {
+	// Fischler & Bolles, "Random Sample Consensus" (1981)
+	if opts == nil {
+		opts = &RANSACOptions{}
+	}
+	threshold := opts.Threshold
+	if threshold <= 0 {
+		threshold = defaultRANSACThreshold
+	}
+	iterations := opts.Iterations
+	if iterations <= 0 {
+		iterations = defaultRANSACIterations
+	}
+	confidence := opts.Confidence
+	if confidence <= 0 || confidence >= 1 {
+		confidence = defaultRANSACConfidence
+	}
+
+	n, size := model.Len(), model.SampleSize()
+	if n < size {
+		return nil
+	}
+
+	r := rand.New(rand.NewSource(opts.Seed))
+
+	// The sample is drawn by shuffling the first indices of the slice
+	indices := make([]int, n)
+	for i := range indices {
+		indices[i] = i
+	}
+	sample := make([]int, size)
+
+	inliers := func() []int {
+		var in []int
+		for i := 0; i < n; i++ {
+			if model.Residual(i) <= threshold {
+				in = append(in, i)
+			}
+		}
+		return in
+	}
+
+	// needed is the number of samples needed to draw one without outliers
+	// with the given confidence, estimated from the best set of inliers.
+	// It's checked on every iteration, not only when a better set is found.
+	var best, bestSample []int
+	needed := math.Inf(1)
+	for iter := 0; iter < iterations; iter++ {
+		for k := range sample {
+			j := k + r.Intn(n-k)
+			indices[k], indices[j] = indices[j], indices[k]
+			sample[k] = indices[k]
+		}
+
+		if model.Fit(sample) {
+			if in := inliers(); len(in) > len(best) {
+				best = in
+				bestSample = append(bestSample[:0], sample...)
+
+				w := float64(len(best)) / float64(n)
+				needed = math.Log(1-confidence) / math.Log(1-math.Pow(w, float64(size)))
+			}
+		}
+
+		if float64(iter+1) >= needed {
+			break
+		}
+	}
+
+	if best == nil {
+		return nil
+	}
+
+	// Refit the model on all the inliers, which can gain or lose a few
+	if !model.Fit(best) {
+		model.Fit(bestSample)
+		return best
+	}
+	if in := inliers(); len(in) >= size && model.Fit(in) {
+		return in
+	}
+	model.Fit(best)
+	return best
+}
+
+// solveLinear solves the system a·x = b with Gaussian elimination. a and b are
+// modified. It returns false if the system is singular.
+func solveLinear(a [][]float64, b []float64) ([]float64, bool) {
+	n := len(b)
+	for col := 0; col < n; col++ {
+		pivot := col
+		for row := col + 1; row < n; row++ {
+			if math.Abs(a[row][col]) > math.Abs(a[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-12 {
+			return nil, false
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		b[col], b[pivot] = b[pivot], b[col]
+
+		for row := col + 1; row < n; row++ {
+			f := a[row][col] / a[col][col]
+			for k := col; k < n; k++ {
+				a[row][k] -= f * a[col][k]
+			}
+			b[row] -= f * b[col]
+		}
+	}
+
+	x := make([]float64, n)
+	for row := n - 1; row >= 0; row-- {
+		v := b[row]
+		for k := row + 1; k < n; k++ {
+			v -= a[row][k] * x[k]
+		}
+		x[row] = v / a[row][row]
+	}
+	return x, true
+}
+
+// leastSquares returns the x that minimizes |a·x - b|² by solving the normal
+// equations.
+func leastSquares(a [][]float64, b []float64) ([]float64, bool) {
+	n := len(a[0])
+	ata := make([][]float64, n)
+	atb := make([]float64, n)
+	for i := range ata {
+		ata[i] = make([]float64, n)
+	}
+	for row, coefs := range a {
+		for i, ci := range coefs {
+			for j, cj := range coefs {
+				ata[i][j] += ci * cj
+			}
+			atb[i] += ci * b[row]
+		}
+	}
+	return solveLinear(ata, atb)
+}
+
+// LineModel is a straight line fitted on points, e.g. the truthy pixels of a
+// BinaryImage given by its Points method.
+type LineModel struct {
+	Points []image.Point
+	// The line is A·x + B·y + C = 0, with A² + B² = 1
+	A, B, C float64
+}
+
+var _ RANSACModel = &LineModel{}
+
+// SampleSize implements the RANSACModel interface
+func (m *LineModel) SampleSize() int { return 2 }
+
+// Len implements the RANSACModel interface
+func (m *LineModel) Len() int { return len(m.Points) }
+
+// Fit implements the RANSACModel interface. The line minimizes the sum of the
+// squared distances to the points.
+func (m *LineModel) Fit(indices []int) bool {
+	var mx, my float64
+	for _, i := range indices {
+		mx += float64(m.Points[i].X)
+		my += float64(m.Points[i].Y)
+	}
+	n := float64(len(indices))
+	mx, my = mx/n, my/n
+
+	var sxx, sxy, syy float64
+	for _, i := range indices {
+		dx, dy := float64(m.Points[i].X)-mx, float64(m.Points[i].Y)-my
+		sxx += dx * dx
+		sxy += dx * dy
+		syy += dy * dy
+	}
+	if sxx+syy == 0 {
+		// all the points are the same
+		return false
+	}
+
+	// The line goes through the centroid along the main axis of the points
+	theta := math.Atan2(2*sxy, sxx-syy) / 2
+	sin, cos := math.Sincos(theta)
+	m.A, m.B = -sin, cos
+	m.C = -(m.A*mx + m.B*my)
+	return true
+}
+
+// Residual implements the RANSACModel interface
+func (m *LineModel) Residual(i int) float64 {
+	p := m.Points[i]
+	return math.Abs(m.A*float64(p.X) + m.B*float64(p.Y) + m.C)
+}
+
+// CircleModel is a circle fitted on points, e.g. the truthy pixels of a
+// BinaryImage given by its Points method.
+type CircleModel struct {
+	Points           []image.Point
+	CenterX, CenterY float64
+	Radius           float64
+}
+
+var _ RANSACModel = &CircleModel{}
+
+// SampleSize implements the RANSACModel interface
+func (m *CircleModel) SampleSize() int { return 3 }
+
+// Len implements the RANSACModel interface
+func (m *CircleModel) Len() int { return len(m.Points) }
+
+// Fit implements the RANSACModel interface. The circle is the algebraic least
+// squares fit of the points.
+func (m *CircleModel) Fit(indices []int) bool {
+	// Kåsa, "A circle fitting procedure and its error analysis" (1976): the
+	// circle x² + y² + D·x + E·y + F = 0 is linear in D, E and F.
+	a := make([][]float64, len(indices))
+	b := make([]float64, len(indices))
+	for k, i := range indices {
+		x, y := float64(m.Points[i].X), float64(m.Points[i].Y)
+		a[k] = []float64{x, y, 1}
+		b[k] = -(x*x + y*y)
+	}
+
+	var coefs []float64
+	var ok bool
+	if len(indices) == 3 {
+		coefs, ok = solveLinear(a, b)
+	} else {
+		coefs, ok = leastSquares(a, b)
+	}
+	if !ok {
+		// the points are aligned
+		return false
+	}
+
+	cx, cy := -coefs[0]/2, -coefs[1]/2
+	r2 := cx*cx + cy*cy - coefs[2]
+	if r2 <= 0 {
+		return false
+	}
+	m.CenterX, m.CenterY, m.Radius = cx, cy, math.Sqrt(r2)
+	return true
+}
+
+// Residual implements the RANSACModel interface
+func (m *CircleModel) Residual(i int) float64 {
+	p := m.Points[i]
+	return math.Abs(math.Hypot(float64(p.X)-m.CenterX, float64(p.Y)-m.CenterY) - m.Radius)
+}
+
+// AffineModel is an affine transformation that maps the points of Src on the
+// ones of Dst, e.g. matched features of two images. It can represent any
+// combination of translation, rotation, scaling and shearing.
+type AffineModel struct {
+	Src, Dst []image.Point
+	// Matrix maps (x, y) to (M[0]·x + M[1]·y + M[2], M[3]·x + M[4]·y + M[5])
+	Matrix [6]float64
+}
+
+var _ RANSACModel = &AffineModel{}
+
+// SampleSize implements the RANSACModel interface
+func (m *AffineModel) SampleSize() int { return 3 }
+
+// Len implements the RANSACModel interface
+func (m *AffineModel) Len() int { return len(m.Src) }
+
+// Fit implements the RANSACModel interface
+func (m *AffineModel) Fit(indices []int) bool {
+	// x' and y' are fitted separately with the same coefficients
+	var rows [2][]float64
+	a := make([][]float64, len(indices))
+	for c := range rows {
+		b := make([]float64, len(indices))
+		for k, i := range indices {
+			a[k] = []float64{float64(m.Src[i].X), float64(m.Src[i].Y), 1}
+			if c == 0 {
+				b[k] = float64(m.Dst[i].X)
+			} else {
+				b[k] = float64(m.Dst[i].Y)
+			}
+		}
+
+		var ok bool
+		if len(indices) == 3 {
+			rows[c], ok = solveLinear(a, b)
+		} else {
+			rows[c], ok = leastSquares(a, b)
+		}
+		if !ok {
+			// the source points are aligned
+			return false
+		}
+	}
+
+	copy(m.Matrix[:3], rows[0])
+	copy(m.Matrix[3:], rows[1])
+	return true
+}
+
+// Apply returns the transformation of (x, y)
+func (m *AffineModel) Apply(x, y float64) (float64, float64) {
+	t := m.Matrix
+	return t[0]*x + t[1]*y + t[2], t[3]*x + t[4]*y + t[5]
+}
+
+// Residual implements the RANSACModel interface
+func (m *AffineModel) Residual(i int) float64 {
+	x, y := m.Apply(float64(m.Src[i].X), float64(m.Src[i].Y))
+	return math.Hypot(x-float64(m.Dst[i].X), y-float64(m.Dst[i].Y))
+}
+
+// HomographyModel is a projective transformation that maps the points of Src
+// on the ones of Dst, e.g. matched features of two images. It's the
+// transformation between two pictures of the same plane, e.g. a document,
+// taken from different points of view.
+type HomographyModel struct {
+	Src, Dst []image.Point
+	// Matrix is the 3x3 matrix of the homography, row by row, with M[8] = 1
+	Matrix [9]float64
+}
+
+var _ RANSACModel = &HomographyModel{}
+
+// SampleSize implements the RANSACModel interface
+func (m *HomographyModel) SampleSize() int { return 4 }
+
+// Len implements the RANSACModel interface
+func (m *HomographyModel) Len() int { return len(m.Src) }
+
+// normalizePoints returns the similarity that moves the centroid of some
+// points to the origin and scales them so that their mean distance to it is
+// √2, as a matrix with the same layout as HomographyModel.Matrix.
+func normalizePoints(points []image.Point, indices []int) [9]float64 {
+	var mx, my float64
+	for _, i := range indices {
+		mx += float64(points[i].X)
+		my += float64(points[i].Y)
+	}
+	n := float64(len(indices))
+	mx, my = mx/n, my/n
+
+	var d float64
+	for _, i := range indices {
+		d += math.Hypot(float64(points[i].X)-mx, float64(points[i].Y)-my)
+	}
+	s := 1.0
+	if d > 0 {
+		s = math.Sqrt2 * n / d
+	}
+	return [9]float64{s, 0, -s * mx, 0, s, -s * my, 0, 0, 1}
+}
+
+// Fit implements the RANSACModel interface
+func (m *HomographyModel) Fit(indices []int) bool {
+	// Direct linear transformation, with the points normalized first; see
+	// Hartley, "In defense of the eight-point algorithm" (1997)
+	ts, td := normalizePoints(m.Src, indices), normalizePoints(m.Dst, indices)
+
+	// Each pair gives two equations on the 8 unknown coefficients
+	a := make([][]float64, 0, 2*len(indices))
+	b := make([]float64, 0, 2*len(indices))
+	for _, i := range indices {
+		x, y := ts[0]*float64(m.Src[i].X)+ts[2], ts[4]*float64(m.Src[i].Y)+ts[5]
+		u, v := td[0]*float64(m.Dst[i].X)+td[2], td[4]*float64(m.Dst[i].Y)+td[5]
+		a = append(a,
+			[]float64{x, y, 1, 0, 0, 0, -u * x, -u * y},
+			[]float64{0, 0, 0, x, y, 1, -v * x, -v * y})
+		b = append(b, u, v)
+	}
+
+	var h []float64
+	var ok bool
+	if len(indices) == 4 {
+		h, ok = solveLinear(a, b)
+	} else {
+		h, ok = leastSquares(a, b)
+	}
+	if !ok {
+		// three of the points are aligned
+		return false
+	}
+
+	// Denormalize: H = Td⁻¹ · H' · Ts
+	normalized := [9]float64{h[0], h[1], h[2], h[3], h[4], h[5], h[6], h[7], 1}
+	tdInv := [9]float64{1 / td[0], 0, -td[2] / td[0], 0, 1 / td[4], -td[5] / td[4], 0, 0, 1}
+	hm := multiply3x3(tdInv, multiply3x3(normalized, ts))
+	if hm[8] == 0 {
+		return false
+	}
+	for k := range hm {
+		m.Matrix[k] = hm[k] / hm[8]
+	}
+	return true
+}
+
+// multiply3x3 returns the product of two 3x3 matrices stored row by row
+func multiply3x3(a, b [9]float64) [9]float64 {
+	var c [9]float64
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			for k := 0; k < 3; k++ {
+				c[3*i+j] += a[3*i+k] * b[3*k+j]
+			}
+		}
+	}
+	return c
+}
+
+// Apply returns the transformation of (x, y). Points mapped to infinity give
+// infinite coordinates.
+func (m *HomographyModel) Apply(x, y float64) (float64, float64) {
+	t := m.Matrix
+	w := t[6]*x + t[7]*y + t[8]
+	if w == 0 {
+		return math.Inf(1), math.Inf(1)
+	}
+	return (t[0]*x + t[1]*y + t[2]) / w, (t[3]*x + t[4]*y + t[5]) / w
+}
+
+// Residual implements the RANSACModel interface
+func (m *HomographyModel) Residual(i int) float64 {
+	x, y := m.Apply(float64(m.Src[i].X), float64(m.Src[i].Y))
+	return math.Hypot(x-float64(m.Dst[i].X), y-float64(m.Dst[i].Y))
+}
@@ -0,0 +1,168 @@
+package leonard
+
+import (
+	"image"
+	"math"
+	"reflect"
+	"testing"
+)
+
+// linePoints returns 40 points of y = 2x + 1 followed by 10 outliers
+func linePoints() []image.Point {
+	var points []image.Point
+	for x := 0; x < 40; x++ {
+		points = append(points, image.Pt(x, 2*x+1))
+	}
+	for k := 0; k < 10; k++ {
+		points = append(points, image.Pt(3*k+2, 90-7*k))
+	}
+	return points
+}
+
+func isRange(indices []int, n int) bool {
+	if len(indices) != n {
+		return false
+	}
+	for i, v := range indices {
+		if v != i {
+			return false
+		}
+	}
+	return true
+}
+
+func TestRANSACLine(t *testing.T) {
+	m := &LineModel{Points: linePoints()}
+	inliers := RANSAC(m, nil)
+
+	if !isRange(inliers, 40) {
+		t.Fatalf("got inliers %v, want the 40 first points", inliers)
+	}
+	// 2x - y + 1 = 0, normalized
+	if a, c := -m.A/m.B, -m.C/m.B; math.Abs(a-2) > 1e-9 || math.Abs(c-1) > 1e-9 {
+		t.Errorf("got y = %gx + %g, want y = 2x + 1", a, c)
+	}
+}
+
+func TestRANSACCircle(t *testing.T) {
+	var points []image.Point
+	for k := 0; k < 36; k++ {
+		sin, cos := math.Sincos(float64(k) * math.Pi / 18)
+		points = append(points, image.Pt(int(math.Round(50+20*cos)), int(math.Round(40+20*sin))))
+	}
+	points = append(points, image.Pt(50, 40), image.Pt(0, 0), image.Pt(90, 10), image.Pt(52, 45))
+
+	m := &CircleModel{Points: points}
+	inliers := RANSAC(m, &RANSACOptions{Threshold: 1})
+
+	if !isRange(inliers, 36) {
+		t.Fatalf("got inliers %v, want the 36 first points", inliers)
+	}
+	if math.Abs(m.CenterX-50) > 0.2 || math.Abs(m.CenterY-40) > 0.2 || math.Abs(m.Radius-20) > 0.2 {
+		t.Errorf("got center (%g, %g) and radius %g", m.CenterX, m.CenterY, m.Radius)
+	}
+}
+
+func TestRANSACAffine(t *testing.T) {
+	var src, dst []image.Point
+	for y := 0; y < 5; y++ {
+		for x := 0; x < 5; x++ {
+			src = append(src, image.Pt(10*x, 10*y))
+			dst = append(dst, image.Pt(2*10*x+10*y+5, 10*y-3))
+		}
+	}
+	src = append(src, image.Pt(3, 4), image.Pt(40, 0))
+	dst = append(dst, image.Pt(100, 100), image.Pt(0, 40))
+
+	m := &AffineModel{Src: src, Dst: dst}
+	inliers := RANSAC(m, nil)
+
+	if !isRange(inliers, 25) {
+		t.Fatalf("got inliers %v, want the 25 first points", inliers)
+	}
+	want := [6]float64{2, 1, 5, 0, 1, -3}
+	for i, v := range m.Matrix {
+		if math.Abs(v-want[i]) > 1e-9 {
+			t.Fatalf("got %v, want %v", m.Matrix, want)
+		}
+	}
+}
+
+func TestRANSACHomography(t *testing.T) {
+	h := HomographyModel{Matrix: [9]float64{1.2, 0.1, 10, -0.05, 0.9, 20, 0.001, 0.0005, 1}}
+
+	var src, dst []image.Point
+	for y := 0; y < 6; y++ {
+		for x := 0; x < 6; x++ {
+			u, v := h.Apply(float64(20*x), float64(20*y))
+			src = append(src, image.Pt(20*x, 20*y))
+			dst = append(dst, image.Pt(int(math.Round(u)), int(math.Round(v))))
+		}
+	}
+	src = append(src, image.Pt(5, 5), image.Pt(50, 70))
+	dst = append(dst, image.Pt(90, 0), image.Pt(0, 90))
+
+	m := &HomographyModel{Src: src, Dst: dst}
+	inliers := RANSAC(m, nil)
+
+	if !isRange(inliers, 36) {
+		t.Fatalf("got inliers %v, want the 36 first points", inliers)
+	}
+	for _, p := range []image.Point{{0, 0}, {100, 0}, {0, 100}, {60, 40}} {
+		x, y := m.Apply(float64(p.X), float64(p.Y))
+		wx, wy := h.Apply(float64(p.X), float64(p.Y))
+		if math.Hypot(x-wx, y-wy) > 1 {
+			t.Errorf("%v: got (%g, %g), want (%g, %g)", p, x, y, wx, wy)
+		}
+	}
+}
+
+func TestRANSACSeed(t *testing.T) {
+	// With a threshold of 0 the inliers depend on the samples
+	points := linePoints()
+	for k := 0; k < 20; k++ {
+		points = append(points, image.Pt(k, k))
+	}
+	opts := &RANSACOptions{Threshold: 1e-9, Iterations: 5, Seed: 42}
+
+	a, b := &LineModel{Points: points}, &LineModel{Points: points}
+	inliersA, inliersB := RANSAC(a, opts), RANSAC(b, opts)
+
+	if !reflect.DeepEqual(inliersA, inliersB) {
+		t.Errorf("got inliers %v and %v", inliersA, inliersB)
+	}
+	if a.A != b.A || a.B != b.B || a.C != b.C {
+		t.Errorf("got models %v and %v", a, b)
+	}
+}
+
+// countingLineModel counts the calls to Fit
+type countingLineModel struct {
+	LineModel
+	fits int
+}
+
+func (m *countingLineModel) Fit(indices []int) bool {
+	m.fits++
+	return m.LineModel.Fit(indices)
+}
+
+func TestRANSACEarlyTermination(t *testing.T) {
+	// 80% of inliers: 5 samples are enough with a confidence of 0.99, even if
+	// the best set is found on the first one.
+	m := &countingLineModel{LineModel: LineModel{Points: linePoints()}}
+	if inliers := RANSAC(m, &RANSACOptions{Iterations: 1000}); !isRange(inliers, 40) {
+		t.Fatalf("got inliers %v, want the 40 first points", inliers)
+	}
+	// plus the refits on the inliers
+	if m.fits > 5+3 {
+		t.Errorf("got %d fits, want at most 8", m.fits)
+	}
+}
+
+func TestRANSACTooFewPoints(t *testing.T) {
+	m := &CircleModel{Points: []image.Point{{0, 0}, {1, 1}}}
+	if inliers := RANSAC(m, nil); inliers != nil {
+		t.Errorf("got %v, want nil", inliers)
+	}
+}
@@ -0,0 +1,133 @@
+package leonard
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// Region holds the statistics of a region while it's grown by GrowRegion
+type Region struct {
+	// Size is the number of pixels of the region
+	Size int
+	// sums of the alpha-premultiplied channels of the pixels
+	sum [4]float64
+}
+
+func (r *Region) add(c color.Color) {
+	cr, cg, cb, ca := c.RGBA()
+	r.sum[0] += float64(cr)
+	r.sum[1] += float64(cg)
+	r.sum[2] += float64(cb)
+	r.sum[3] += float64(ca)
+	r.Size++
+}
+
+// Mean returns the mean color of the pixels of the region
+func (r *Region) Mean() color.RGBA64 {
+	if r.Size == 0 {
+		return color.RGBA64{}
+	}
+	n := float64(r.Size)
+	return color.RGBA64{
+		uint16(r.sum[0]/n + 0.5),
+		uint16(r.sum[1]/n + 0.5),
+		uint16(r.sum[2]/n + 0.5),
+		uint16(r.sum[3]/n + 0.5),
+	}
+}
+
+// SimilarityPredicate tells if a pixel of the given color can be added to a
+// region.
+type SimilarityPredicate func(c color.Color, region *Region) bool
+
+// colorDifference returns the highest difference between the channels of two
+// colors, between 0 and 1.
+func colorDifference(a, b color.Color) float64 {
+	ar, ag, ab, aa := a.RGBA()
+	br, bg, bb, ba := b.RGBA()
+
+	diff := 0.0
+	for _, d := range []float64{
+		float64(ar) - float64(br),
+		float64(ag) - float64(bg),
+		float64(ab) - float64(bb),
+		float64(aa) - float64(ba),
+	} {
+		diff = math.Max(diff, math.Abs(d))
+	}
+	return diff / 0xFFFF
+}
+
+// MeanSimilarity returns a predicate that accepts the pixels whose channels
+// differ from the mean color of the region by at most tolerance, between 0
+// and 1.
+func MeanSimilarity(tolerance float64) SimilarityPredicate {
+	return func(c color.Color, region *Region) bool {
+		return colorDifference(c, region.Mean()) <= tolerance
+	}
+}
+
+// GrowRegion grows a region from the given seeds: neighbours of the pixels of
+// the region are added to it as long as similar accepts them. The seeds are
+// always part of the region, except those outside of the image. It returns the
+// mask of the region.
+func GrowRegion(img image.Image, seeds []image.Point, connectivity Connectivity, similar SimilarityPredicate) *BinaryImage {
+	offsets := connectivity.offsets()
+	bounds := img.Bounds()
+	mask := NewEmptyBinaryImage(bounds.Max.Y, bounds.Max.X)
+
+	region := &Region{}
+	var queue []image.Point
+
+	for _, p := range seeds {
+		if !p.In(bounds) || mask.Get(p.X, p.Y) {
+			continue
+		}
+		mask.Set(p.X, p.Y, true)
+		region.add(img.At(p.X, p.Y))
+		queue = append(queue, p)
+	}
+
+	// Pixels rejected once aren't tested again when they're reached from
+	// another pixel of the region.
+	rejected := make(map[image.Point]bool)
+
+	for ; len(queue) > 0; queue = queue[1:] {
+		p := queue[0]
+		for _, o := range offsets {
+			q := image.Point{p.X + o.X, p.Y + o.Y}
+			if !q.In(bounds) || mask.Get(q.X, q.Y) || rejected[q] {
+				continue
+			}
+
+			c := img.At(q.X, q.Y)
+			if !similar(c, region) {
+				rejected[q] = true
+				continue
+			}
+
+			mask.Set(q.X, q.Y, true)
+			region.add(c)
+			queue = append(queue, q)
+		}
+	}
+
+	return mask
+}
+
+// FloodFill returns the mask of the pixels connected to seed whose channels
+// differ from the seed's by at most tolerance, between 0 and 1. This is what
+// image editors call a magic wand. The mask is empty if the seed is outside of
+// the image.
+func FloodFill(img image.Image, seed image.Point, tolerance float64, connectivity Connectivity) *BinaryImage {
+	if !seed.In(img.Bounds()) {
+		bounds := img.Bounds()
+		return NewEmptyBinaryImage(bounds.Max.Y, bounds.Max.X)
+	}
+
+	reference := img.At(seed.X, seed.Y)
+	return GrowRegion(img, []image.Point{seed}, connectivity, func(c color.Color, _ *Region) bool {
+		return colorDifference(c, reference) <= tolerance
+	})
+}
@@ -0,0 +1,89 @@
+package leonard
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// rings returns a binary image with a white square ring around a black
+// square, on a black background.
+func rings() *BinaryImage {
+	return newBinaryImage(
+		".......",
+		".#####.",
+		".#...#.",
+		".#...#.",
+		".#...#.",
+		".#####.",
+		".......",
+	)
+}
+
+func TestFloodFill(t *testing.T) {
+	img := rings()
+
+	inside := FloodFill(img, image.Pt(3, 3), 0.1, Connectivity4)
+	if got := len(inside.Points()); got != 9 {
+		t.Errorf("got %d pixels inside the ring, want 9", got)
+	}
+	if inside.Get(0, 0) {
+		t.Error("the fill leaked outside of the ring")
+	}
+
+	ring := FloodFill(img, image.Pt(1, 1), 0.1, Connectivity8)
+	if got := len(ring.Points()); got != 16 {
+		t.Errorf("got %d pixels in the ring, want 16", got)
+	}
+}
+
+func TestFloodFillConnectivity(t *testing.T) {
+	diagonal := newBinaryImage(
+		"#..",
+		".#.",
+		"..#",
+	)
+
+	if got := len(FloodFill(diagonal, image.Pt(0, 0), 0, Connectivity4).Points()); got != 1 {
+		t.Errorf("4-connectivity: got %d pixels, want 1", got)
+	}
+	if got := len(FloodFill(diagonal, image.Pt(0, 0), 0, Connectivity8).Points()); got != 3 {
+		t.Errorf("8-connectivity: got %d pixels, want 3", got)
+	}
+}
+
+func TestFloodFillTolerance(t *testing.T) {
+	ramp := newGrayImage(10, 1, func(x, y int) uint8 { return uint8(10 * x) })
+
+	// each step is 10/255 ≈ 0.039 away from the seed's value
+	if got := len(FloodFill(ramp, image.Pt(0, 0), 0.1, Connectivity4).Points()); got != 3 {
+		t.Errorf("got %d pixels, want 3", got)
+	}
+	if got := len(FloodFill(ramp, image.Pt(0, 0), 1, Connectivity4).Points()); got != 10 {
+		t.Errorf("got %d pixels, want 10", got)
+	}
+}
+
+func TestFloodFillOutside(t *testing.T) {
+	if got := FloodFill(rings(), image.Pt(-1, 3), 1, Connectivity4).Points(); len(got) != 0 {
+		t.Errorf("got %v, want an empty mask", got)
+	}
+}
+
+func TestGrowRegion(t *testing.T) {
+	// The mean of the region follows the ramp, so it grows further than a
+	// flood fill with the same tolerance.
+	ramp := newGrayImage(10, 1, func(x, y int) uint8 { return uint8(10 * x) })
+
+	mask := GrowRegion(ramp, []image.Point{{0, 0}}, Connectivity4, MeanSimilarity(0.1))
+	if got := len(mask.Points()); got <= 3 {
+		t.Errorf("got %d pixels, want more than 3", got)
+	}
+
+	// seeds are always part of the region
+	seeds := []image.Point{{0, 0}, {9, 0}, {20, 0}}
+	mask = GrowRegion(ramp, seeds, Connectivity4, func(_ color.Color, _ *Region) bool { return false })
+	if got := mask.Points(); len(got) != 2 {
+		t.Errorf("got %v, want the 2 seeds in the image", got)
+	}
+}
@@ -19,11 +19,11 @@ func averageColor(colors []color.Color) color.Color {
 
 	n := float64(len(colors))
 
-	return color.NRGBA{
-		uint8(to255(r / n)),
-		uint8(to255(g / n)),
-		uint8(to255(b / n)),
-		uint8(to255(a / n)),
+	return color.RGBA64{
+		uint16(r/n + 0.5),
+		uint16(g/n + 0.5),
+		uint16(b/n + 0.5),
+		uint16(a/n + 0.5),
 	}
 }
 
@@ -39,7 +39,7 @@ func Downscale(img image.Image) image.Image {
 	width2 := int(math.Ceil(float64(width) / 2.0))
 	height2 := int(math.Ceil(float64(height) / 2.0))
 
-	downscaled := image.NewRGBA(image.Rect(0, 0, width2, height2))
+	downscaled := newImageLike(image.Rect(0, 0, width2, height2), img)
 
 	for y := 0; y < height2; y++ {
 		for x := 0; x < width2; x++ {
@@ -57,3 +57,92 @@ func Downscale(img image.Image) image.Image {
 
 	return downscaled
 }
+
+// Resize resizes an image to the given width and height using a bilinear
+// interpolation. If one of them is 0 it's computed from the other in order to
+// preserve the aspect ratio. An empty image gives an empty image. It panics if
+// width or height is negative.
+//
+// Bilinear interpolation gives poor results when reducing the size of an image
+// by more than 2x; apply Downscale first in that case.
+func Resize(img image.Image, width, height int) image.Image {
+	// https://en.wikipedia.org/wiki/Bilinear_interpolation
+
+	if width < 0 || height < 0 {
+		panic("Resize: negative size")
+	}
+
+	bounds := img.Bounds()
+	srcWidth, srcHeight := bounds.Dx(), bounds.Dy()
+
+	// there's nothing to interpolate, and no aspect ratio to preserve
+	if srcWidth == 0 || srcHeight == 0 {
+		return newImageLike(image.Rectangle{}, img)
+	}
+
+	if width == 0 && height == 0 {
+		width, height = srcWidth, srcHeight
+	} else if width == 0 {
+		width = int(math.Max(1, math.Floor(float64(srcWidth*height)/float64(srcHeight)+0.5)))
+	} else if height == 0 {
+		height = int(math.Max(1, math.Floor(float64(srcHeight*width)/float64(srcWidth)+0.5)))
+	}
+
+	resized := newImageLike(image.Rect(0, 0, width, height), img)
+
+	scaleX := float64(srcWidth) / float64(width)
+	scaleY := float64(srcHeight) / float64(height)
+
+	for y := 0; y < height; y++ {
+		// map the center of the destination pixel in the source image
+		sy := (float64(y)+0.5)*scaleY - 0.5
+		y0 := int(math.Floor(sy))
+		dy := sy - float64(y0)
+		y1 := clampInt(y0+1, 0, srcHeight-1) + bounds.Min.Y
+		y0 = clampInt(y0, 0, srcHeight-1) + bounds.Min.Y
+
+		for x := 0; x < width; x++ {
+			sx := (float64(x)+0.5)*scaleX - 0.5
+			x0 := int(math.Floor(sx))
+			dx := sx - float64(x0)
+			x1 := clampInt(x0+1, 0, srcWidth-1) + bounds.Min.X
+			x0 = clampInt(x0, 0, srcWidth-1) + bounds.Min.X
+
+			resized.Set(x, y, interpolateColors(
+				img.At(x0, y0), img.At(x1, y0),
+				img.At(x0, y1), img.At(x1, y1),
+				dx, dy))
+		}
+	}
+
+	return resized
+}
+
+// interpolateColors performs a bilinear interpolation between four colors:
+// c00 is the top-left one, c10 the top-right one, etc.
+func interpolateColors(c00, c10, c01, c11 color.Color, dx, dy float64) color.Color {
+	w00 := (1 - dx) * (1 - dy)
+	w10 := dx * (1 - dy)
+	w01 := (1 - dx) * dy
+	w11 := dx * dy
+
+	var rgba [4]float64
+
+	for _, wc := range []struct {
+		w float64
+		c color.Color
+	}{{w00, c00}, {w10, c10}, {w01, c01}, {w11, c11}} {
+		r, g, b, a := wc.c.RGBA()
+		rgba[0] += wc.w * float64(r)
+		rgba[1] += wc.w * float64(g)
+		rgba[2] += wc.w * float64(b)
+		rgba[3] += wc.w * float64(a)
+	}
+
+	return color.RGBA64{
+		uint16(rgba[0] + 0.5),
+		uint16(rgba[1] + 0.5),
+		uint16(rgba[2] + 0.5),
+		uint16(rgba[3] + 0.5),
+	}
+}
@@ -0,0 +1,104 @@
+package leonard
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestResizeSize(t *testing.T) {
+	img := uniformGray(40, 20, 100)
+
+	for _, tt := range []struct {
+		w, h int
+		want image.Point
+	}{
+		{0, 0, image.Pt(40, 20)},
+		{20, 0, image.Pt(20, 10)},
+		{0, 5, image.Pt(10, 5)},
+		{7, 3, image.Pt(7, 3)},
+	} {
+		if got := Resize(img, tt.w, tt.h).Bounds().Size(); got != tt.want {
+			t.Errorf("%dx%d: got %v, want %v", tt.w, tt.h, got, tt.want)
+		}
+	}
+}
+
+func TestResizeUniform(t *testing.T) {
+	img := uniformGray(10, 10, 100)
+	want := gray16At(img, 0, 0)
+
+	resized := Resize(img, 23, 7)
+	for y := 0; y < 7; y++ {
+		for x := 0; x < 23; x++ {
+			if got := gray16At(resized, x, y); got != want {
+				t.Fatalf("got %d at (%d, %d), want %d", got, x, y, want)
+			}
+		}
+	}
+}
+
+func TestResizeEmpty(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 0, 10))
+
+	for _, size := range []image.Point{{0, 5}, {5, 0}, {5, 5}} {
+		if got := Resize(img, size.X, size.Y).Bounds(); !got.Empty() {
+			t.Errorf("%v: got %v, want an empty image", size, got)
+		}
+	}
+}
+
+func TestResizeNegativeSize(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic")
+		}
+	}()
+	Resize(uniformGray(4, 4, 0), -1, 2)
+}
+
+func TestAverageColor16Bit(t *testing.T) {
+	got := averageColor([]color.Color{
+		color.Gray16{1000}, color.Gray16{1001}, color.Gray16{1001}, color.Gray16{1002},
+	})
+	if c := color.Gray16Model.Convert(got).(color.Gray16); c.Y != 1001 {
+		t.Errorf("got %d, want 1001", c.Y)
+	}
+
+	got = averageColor([]color.Color{
+		color.RGBA64{0x0101, 0x0200, 0xfffe, 0xffff},
+		color.RGBA64{0x0102, 0x0203, 0xffff, 0xffff},
+	})
+	if want := (color.RGBA64{0x0102, 0x0202, 0xffff, 0xffff}); got != want {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestDownscaleGray16(t *testing.T) {
+	img := newGray16Image(4, 2, func(x, y int) uint16 { return 1000 + uint16(x+2*y) })
+
+	downscaled := Downscale(img)
+	if _, ok := downscaled.(*image.Gray16); !ok {
+		t.Fatalf("got a %T, want an *image.Gray16", downscaled)
+	}
+	// (1000 + 1001 + 1002 + 1003) / 4, rounded
+	if got := gray16At(downscaled, 0, 0); got != 1002 {
+		t.Errorf("got %d, want 1002", got)
+	}
+	if got := gray16At(downscaled, 1, 0); got != 1004 {
+		t.Errorf("got %d, want 1004", got)
+	}
+}
+
+func TestResizeGray16(t *testing.T) {
+	img := newGray16Image(2, 1, func(x, y int) uint16 { return 1000 + 2*uint16(x) })
+
+	resized := Resize(img, 3, 1)
+	if _, ok := resized.(*image.Gray16); !ok {
+		t.Fatalf("got a %T, want an *image.Gray16", resized)
+	}
+	// the middle pixel is halfway between the two original ones
+	if got := gray16At(resized, 1, 0); got != 1001 {
+		t.Errorf("got %d, want 1001", got)
+	}
+}
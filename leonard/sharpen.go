@@ -0,0 +1,158 @@
+package leonard
+
+import (
+	"image"
+	"image/color"
+)
+
+// clamp16 clamps a value in the 0-0xFFFF range
+func clamp16(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	if v > 0xFFFF {
+		return 0xFFFF
+	}
+	return v
+}
+
+// sharpen combines each pixel of an image with the corresponding one in a
+// "detail" image. fn is called on each channel of each pixel with the original
+// and detail values in the 0-0xFFFF range and returns the new value.
+func sharpen(img, detail image.Image, fn func(orig, detail float64) float64) image.Image {
+	bounds := img.Bounds()
+	sharpened := newImageLike(bounds, img)
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			dr, dg, db, _ := detail.At(x, y).RGBA()
+
+			// Colors are alpha-premultiplied so a channel can't be higher than
+			// the alpha one.
+			alpha := float64(a)
+			channel := func(orig, detail uint32) uint16 {
+				v := clamp16(fn(float64(orig), float64(detail)))
+				if v > alpha {
+					v = alpha
+				}
+				return uint16(v)
+			}
+
+			sharpened.Set(x, y, color.RGBA64{
+				channel(r, dr),
+				channel(g, dg),
+				channel(b, db),
+				uint16(a),
+			})
+		}
+	}
+
+	return sharpened
+}
+
+// UnsharpMask sharpens an image by adding to it the difference between itself
+// and a blurred copy of it, multiplied by amount. sigma is the parameter of
+// the gaussian filter used to blur the image. Pixels whose difference with the
+// blurred image is lower than threshold (0-0xFFFF) are left unchanged; this
+// avoids amplifying the noise in flat areas.
+func UnsharpMask(img image.Image, sigma, amount float64, threshold int) image.Image {
+	// https://en.wikipedia.org/wiki/Unsharp_masking
+	// http://homepages.inf.ed.ac.uk/rbf/HIPR2/unsharp.htm
+	t := float64(threshold)
+
+	return sharpen(img, GaussianFilter(img, sigma), func(orig, blured float64) float64 {
+		mask := orig - blured
+		if mask < t && -mask < t {
+			return orig
+		}
+		return orig + amount*mask
+	})
+}
+
+// HighBoost applies a high-boost filter on the image: the result is the
+// original image multiplied by boost, minus a blurred copy of it. A boost of 1
+// gives a high-pass filter; higher values keep more of the original image.
+func HighBoost(img image.Image, sigma, boost float64) image.Image {
+	// Note boost·I - blur(I) = (boost-1)·I + (I - blur(I)): the result is the
+	// image multiplied by (boost - 1), plus the mask of an unsharp mask with
+	// an amount of 1.
+	return sharpen(img, GaussianFilter(img, sigma), func(orig, blured float64) float64 {
+		return boost*orig - blured
+	})
+}
+
+// Laplacian returns the Laplacian of the red, green and blue channels of an
+// image, as a signed 3-channels FloatImage. Use SignedPolicy to visualize it.
+func Laplacian(img image.Image) *FloatImage {
+	// We use the 8-neighbours kernel:
+	//
+	//     1  1  1
+	//     1 -8  1
+	//     1  1  1
+	//
+	// The image is extended by repeating its border pixels.
+	//
+	// See http://homepages.inf.ed.ac.uk/rbf/HIPR2/log.htm
+	bounds := img.Bounds()
+	lap := NewFloatImage(bounds, 3)
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, _ := img.At(x, y).RGBA()
+			lr, lg, lb := -8*float64(r), -8*float64(g), -8*float64(b)
+
+			for _, o := range clockwiseOffsets {
+				nx, ny := o.apply(x, y)
+				nx = clampInt(nx, bounds.Min.X, bounds.Max.X-1)
+				ny = clampInt(ny, bounds.Min.Y, bounds.Max.Y-1)
+
+				nr, ng, nb, _ := img.At(nx, ny).RGBA()
+				lr += float64(nr)
+				lg += float64(ng)
+				lb += float64(nb)
+			}
+
+			i := lap.PixOffset(x, y)
+			lap.Pix[i] = float32(lr / 0xFFFF)
+			lap.Pix[i+1] = float32(lg / 0xFFFF)
+			lap.Pix[i+2] = float32(lb / 0xFFFF)
+		}
+	}
+
+	return lap
+}
+
+// LaplacianSharpen sharpens an image by subtracting its Laplacian multiplied by
+// strength from it.
+func LaplacianSharpen(img image.Image, strength float64) image.Image {
+	lap := Laplacian(img)
+
+	bounds := img.Bounds()
+	sharpened := newImageLike(bounds, img)
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			i := lap.PixOffset(x, y)
+
+			alpha := float64(a)
+			channel := func(orig uint32, l float32) uint16 {
+				v := clamp16(float64(orig) - strength*float64(l)*0xFFFF)
+				if v > alpha {
+					v = alpha
+				}
+				return uint16(v)
+			}
+
+			sharpened.Set(x, y, color.RGBA64{
+				channel(r, lap.Pix[i]),
+				channel(g, lap.Pix[i+1]),
+				channel(b, lap.Pix[i+2]),
+				uint16(a),
+			})
+		}
+	}
+
+	return sharpened
+}
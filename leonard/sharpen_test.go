@@ -0,0 +1,71 @@
+package leonard
+
+import (
+	"image"
+	"testing"
+)
+
+func TestSharpenUniform(t *testing.T) {
+	img := uniformGray(8, 8, 100)
+	want := gray16At(img, 0, 0)
+
+	for name, sharpened := range map[string]image.Image{
+		"UnsharpMask":      UnsharpMask(img, 1, 2, 0),
+		"HighBoost":        HighBoost(img, 1, 2),
+		"LaplacianSharpen": LaplacianSharpen(img, 1),
+	} {
+		for _, p := range []image.Point{{0, 0}, {3, 4}, {7, 7}} {
+			if got := gray16At(sharpened, p.X, p.Y); got != want {
+				t.Errorf("%s: got %d at %v, want %d", name, got, p, want)
+			}
+		}
+	}
+}
+
+func TestHighBoostHighPass(t *testing.T) {
+	// With a boost of 1 only the details are kept
+	img := uniformGray(8, 8, 100)
+	if got := gray16At(HighBoost(img, 1, 1), 4, 4); got != 0 {
+		t.Errorf("got %d, want 0", got)
+	}
+}
+
+func TestHighBoostIsScaledUnsharpMask(t *testing.T) {
+	// boost·I - blur(I) = (boost-1)·I + (I - blur(I)), so with a boost of 2
+	// it's an unsharp mask with an amount of 1.
+	img := newGrayImage(16, 16, func(x, y int) uint8 {
+		if x < 8 {
+			return 80
+		}
+		return 160
+	})
+
+	boosted := HighBoost(img, 1, 2)
+	unsharp := UnsharpMask(img, 1, 1, 0)
+
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			a, b := int(gray16At(boosted, x, y)), int(gray16At(unsharp, x, y))
+			if d := a - b; d < -1 || d > 1 {
+				t.Fatalf("got %d at (%d, %d), want %d", a, x, y, b)
+			}
+		}
+	}
+}
+
+func TestUnsharpMaskSharpensEdges(t *testing.T) {
+	img := newGrayImage(16, 4, func(x, y int) uint8 {
+		if x < 8 {
+			return 80
+		}
+		return 160
+	})
+	sharpened := UnsharpMask(img, 1, 1, 0)
+
+	if dark, orig := gray16At(sharpened, 7, 2), gray16At(img, 7, 2); dark >= orig {
+		t.Errorf("dark side of the edge: got %d, want less than %d", dark, orig)
+	}
+	if bright, orig := gray16At(sharpened, 8, 2), gray16At(img, 8, 2); bright <= orig {
+		t.Errorf("bright side of the edge: got %d, want more than %d", bright, orig)
+	}
+}
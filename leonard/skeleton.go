@@ -0,0 +1,442 @@
+package leonard
+
+import (
+	"image"
+	"math"
+	"sort"
+)
+
+// ThinningAlgorithm is an algorithm used to thin the shapes of a binary image
+// down to their skeleton.
+type ThinningAlgorithm int
+
+const (
+	// ZhangSuen is Zhang-Suen's algorithm (1984) with Kocharyan (2013)'s
+	// modifications; see ThinEdges.
+	ZhangSuen ThinningAlgorithm = iota
+	// ZhangWang is Zhang-Wang's algorithm (1988). It deletes pixels in one
+	// pass per iteration instead of two, looking at a 4x4 window around each
+	// pixel.
+	ZhangWang
+	// GuoHall is Guo-Hall's algorithm (1989). It gives thinner skeletons
+	// than Zhang-Suen's, with fewer staircases.
+	GuoHall
+	// MedialAxis deletes the pixels in the order of their distance to the
+	// background, so that the skeleton is at equal distance of the borders of
+	// the shapes; see MedialAxisTransform.
+	MedialAxis
+)
+
+// neighbourhood holds the values of the 8 neighbours of a pixel in clockwise
+// order, starting from the north:
+//
+//	p9 p2 p3
+//	p8 P1 p4
+//	p7 p6 p5
+type neighbourhood [8]bool
+
+func (b *BinaryImage) neighbours(x, y int) (n neighbourhood) {
+	for i, o := range clockwiseOffsets {
+		n[i] = b.Get(o.apply(x, y))
+	}
+	return
+}
+
+// count returns the number of truthy neighbours; that's B(P1) in the papers
+func (n neighbourhood) count() int {
+	c := 0
+	for _, v := range n {
+		if v {
+			c++
+		}
+	}
+	return c
+}
+
+// crossings returns the number of false->true transitions when going around
+// the neighbours; that's A(P1) in the papers.
+func (n neighbourhood) crossings() int {
+	c := 0
+	for i, v := range n {
+		if !v && n[(i+1)%8] {
+			c++
+		}
+	}
+	return c
+}
+
+// connectivity returns Yokoi's 8-connectivity number of a pixel. A pixel whose
+// connectivity number is 1 can be deleted without changing the topology of the
+// image.
+func (n neighbourhood) connectivity() int {
+	c := 0
+	for k := 0; k < 8; k += 2 {
+		if !n[k] && !(!n[(k+1)%8] && !n[(k+2)%8]) {
+			c++
+		}
+	}
+	return c
+}
+
+// thin deletes the pixels for which remove returns true until there's none
+// left. Each iteration is made of the given number of subiterations, in which
+// all the pixels are tested in parallel on the image as it was at the start of
+// the subiteration.
+func (b *BinaryImage) thin(subiterations int, remove func(b *BinaryImage, x, y, subiteration int) bool) {
+	for changed := true; changed; {
+		changed = false
+
+		for sub := 0; sub < subiterations; sub++ {
+			var removed []image.Point
+			b.EachPixel(func(x, y int) {
+				if remove(b, x, y, sub) {
+					removed = append(removed, image.Point{x, y})
+				}
+			})
+
+			for _, p := range removed {
+				b.Set(p.X, p.Y, false)
+			}
+			if len(removed) > 0 {
+				changed = true
+			}
+		}
+	}
+}
+
+func zhangWangRemove(b *BinaryImage, x, y, _ int) bool {
+	// See Zhang & Wang, "A modified parallel thinning algorithm" (1988)
+	n := b.neighbours(x, y)
+	p2, p4, p6, p8 := n[0], n[2], n[4], n[6]
+
+	if count := n.count(); count < 2 || count > 6 {
+		return false
+	}
+	if n.crossings() != 1 {
+		return false
+	}
+
+	// Look one pixel further in the north and east directions so that lines
+	// two pixels thick don't disappear.
+	if p2 && p4 && p8 && b.neighbours(north.apply(x, y)).crossings() == 1 {
+		return false
+	}
+	if p2 && p4 && p6 && b.neighbours(east.apply(x, y)).crossings() == 1 {
+		return false
+	}
+	return true
+}
+
+func guoHallRemove(b *BinaryImage, x, y, subiteration int) bool {
+	// See Guo & Hall, "Parallel thinning with two-subiteration algorithms"
+	// (1989)
+	n := b.neighbours(x, y)
+	p2, p3, p4, p5, p6, p7, p8, p9 := n[0], n[1], n[2], n[3], n[4], n[5], n[6], n[7]
+
+	b2i := func(v bool) int {
+		if v {
+			return 1
+		}
+		return 0
+	}
+
+	c := b2i(!p2 && (p3 || p4)) + b2i(!p4 && (p5 || p6)) +
+		b2i(!p6 && (p7 || p8)) + b2i(!p8 && (p9 || p2))
+	if c != 1 {
+		return false
+	}
+
+	n1 := b2i(p9 || p2) + b2i(p3 || p4) + b2i(p5 || p6) + b2i(p7 || p8)
+	n2 := b2i(p2 || p3) + b2i(p4 || p5) + b2i(p6 || p7) + b2i(p8 || p9)
+	if n1 > n2 {
+		n1 = n2
+	}
+	if n1 < 2 || n1 > 3 {
+		return false
+	}
+
+	if subiteration == 0 {
+		return !((p6 || p7 || !p9) && p8)
+	}
+	return !((p2 || p3 || !p5) && p4)
+}
+
+// Thin thins the shapes of the image down to their skeleton with the given
+// algorithm and returns it. The image is modified in-place.
+func (b *BinaryImage) Thin(algorithm ThinningAlgorithm) *BinaryImage {
+	switch algorithm {
+	case ZhangSuen:
+		return b.ThinEdges()
+	case ZhangWang:
+		b.thin(1, zhangWangRemove)
+	case GuoHall:
+		b.thin(2, guoHallRemove)
+	case MedialAxis:
+		skeleton, _ := b.MedialAxisTransform()
+		b.pixels = skeleton.pixels
+	default:
+		panic("Invalid thinning algorithm")
+	}
+	return b
+}
+
+// MedialAxisTransform returns the medial axis of the shapes of the image,
+// along with the distance from each of its pixels to the closest border. The
+// distance is 0 outside of the medial axis. The image is not modified.
+//
+// Pixels outside of the image are considered false, so shapes that touch its
+// borders are cut there.
+func (b *BinaryImage) MedialAxisTransform() (*BinaryImage, *FloatImage) {
+	// Compute the distances to the false pixels on a grid with a 1-pixel
+	// border around the image.
+	w := b.width + 2
+	sq, _ := euclideanTransform(w, b.height+2, func(x, y int) bool {
+		return !b.Get(x-1, y-1)
+	})
+	distance := func(p image.Point) float64 { return sq[(p.Y+1)*w+p.X+1] }
+
+	// Pixels close to the borders are tested first; ties are broken by the
+	// position so that the result doesn't depend on the map ordering.
+	points := b.Points()
+	sort.SliceStable(points, func(i, j int) bool {
+		return distance(points[i]) < distance(points[j])
+	})
+
+	// ridge tests if a pixel is at least as far from the borders as its
+	// neighbours
+	ridge := func(p image.Point) bool {
+		for _, o := range clockwiseOffsets {
+			q := image.Point{p.X + o.X, p.Y + o.Y}
+			if b.Get(q.X, q.Y) && distance(q) > distance(p) {
+				return false
+			}
+		}
+		return true
+	}
+
+	skeleton := b.Clone()
+
+	for changed := true; changed; {
+		changed = false
+		for _, p := range points {
+			if !skeleton.Get(p.X, p.Y) {
+				continue
+			}
+			n := skeleton.neighbours(p.X, p.Y)
+			// Keep the pixels that can't be removed without disconnecting
+			// the shape or creating a hole, and the end of the branches that
+			// are on the ridge of the distances.
+			if n.connectivity() == 1 && (n.count() > 1 || !ridge(p)) {
+				skeleton.Set(p.X, p.Y, false)
+				changed = true
+			}
+		}
+	}
+
+	distances := NewFloatImage(b.Bounds(), 1)
+	skeleton.EachPixel(func(x, y int) {
+		distances.SetFloat(x, y, 0, float32(math.Sqrt(distance(image.Point{x, y}))))
+	})
+
+	return skeleton, distances
+}
+
+// SkeletonNode is an endpoint or a junction of a skeleton
+type SkeletonNode struct {
+	// Point is the first pixel of the node from top to bottom and left to
+	// right.
+	image.Point
+	// Pixels are all the pixels of the node. Junctions may span several
+	// adjacent pixels; endpoints always have one.
+	Pixels   []image.Point
+	Junction bool
+}
+
+// SkeletonEdge is a branch of a skeleton between two nodes
+type SkeletonEdge struct {
+	// From and To are indexes in the nodes of the graph
+	From, To int
+	// Pixels of the branch from From to To, excluding the nodes' ones
+	Pixels []image.Point
+}
+
+// SkeletonGraph is the graph of a skeleton, e.g. one given by Thin. Its nodes
+// are the endpoints and junctions of the skeleton, and its edges the branches
+// between them. Closed loops without any junction aren't part of the graph.
+type SkeletonGraph struct {
+	Nodes []SkeletonNode
+	Edges []SkeletonEdge
+}
+
+// Endpoints returns the endpoints of the skeleton
+func (g *SkeletonGraph) Endpoints() []image.Point {
+	var points []image.Point
+	for _, n := range g.Nodes {
+		if !n.Junction {
+			points = append(points, n.Point)
+		}
+	}
+	return points
+}
+
+// Junctions returns the junctions of the skeleton, one pixel per junction
+func (g *SkeletonGraph) Junctions() []image.Point {
+	var points []image.Point
+	for _, n := range g.Nodes {
+		if n.Junction {
+			points = append(points, n.Point)
+		}
+	}
+	return points
+}
+
+// NewSkeletonGraph extracts the graph of a skeleton. Nodes are ordered from
+// top to bottom and left to right.
+func NewSkeletonGraph(b *BinaryImage) *SkeletonGraph {
+	g := &SkeletonGraph{}
+
+	points := b.Points()
+
+	// index of the node each node pixel is part of
+	nodes := make(map[image.Point]int)
+
+	isJunction := func(p image.Point) bool {
+		return b.neighbours(p.X, p.Y).crossings() >= 3
+	}
+
+	for _, p := range points {
+		if _, ok := nodes[p]; ok {
+			continue
+		}
+
+		n := b.neighbours(p.X, p.Y)
+
+		if n.count() == 0 || (n.crossings() == 1 && n.count() <= 3) {
+			nodes[p] = len(g.Nodes)
+			g.Nodes = append(g.Nodes, SkeletonNode{Point: p, Pixels: []image.Point{p}})
+			continue
+		}
+
+		if !isJunction(p) {
+			continue
+		}
+
+		// Group the adjacent junction pixels
+		node := SkeletonNode{Point: p, Junction: true}
+		i := len(g.Nodes)
+		nodes[p] = i
+		for queue := []image.Point{p}; len(queue) > 0; queue = queue[1:] {
+			q := queue[0]
+			node.Pixels = append(node.Pixels, q)
+			for _, o := range clockwiseOffsets {
+				r := image.Point{q.X + o.X, q.Y + o.Y}
+				if _, ok := nodes[r]; !ok && b.Get(r.X, r.Y) && isJunction(r) {
+					nodes[r] = i
+					queue = append(queue, r)
+				}
+			}
+		}
+		g.Nodes = append(g.Nodes, node)
+	}
+
+	// Pixels that are part of an edge
+	used := make(map[image.Point]bool)
+	// Pairs of adjacent nodes
+	adjacent := make(map[[2]int]bool)
+
+	// 4-neighbours are tried first so that we follow staircases instead of
+	// skipping their steps.
+	offsets := []offset{north, east, south, west, northeast, southeast, southwest, northwest}
+
+	for i, node := range g.Nodes {
+		for _, q := range node.Pixels {
+			for _, o := range offsets {
+				r := image.Point{q.X + o.X, q.Y + o.Y}
+				if !b.Get(r.X, r.Y) || used[r] {
+					continue
+				}
+
+				if j, ok := nodes[r]; ok {
+					if j != i && !adjacent[[2]int{i, j}] {
+						adjacent[[2]int{i, j}] = true
+						adjacent[[2]int{j, i}] = true
+						if i < j {
+							g.Edges = append(g.Edges, SkeletonEdge{From: i, To: j})
+						}
+					}
+					continue
+				}
+
+				// Follow the branch until we reach a node
+				path := []image.Point{r}
+				used[r] = true
+
+			follow:
+				for {
+					cur := path[len(path)-1]
+
+					for _, o := range offsets {
+						s := image.Point{cur.X + o.X, cur.Y + o.Y}
+						// The first pixels of a branch may touch the node it
+						// starts from.
+						if j, ok := nodes[s]; ok && (j != i || len(path) > 2) {
+							g.Edges = append(g.Edges, SkeletonEdge{From: i, To: j, Pixels: path})
+							break follow
+						}
+					}
+
+					next := false
+					for _, o := range offsets {
+						s := image.Point{cur.X + o.X, cur.Y + o.Y}
+						if _, ok := nodes[s]; !ok && b.Get(s.X, s.Y) && !used[s] {
+							path = append(path, s)
+							used[s] = true
+							next = true
+							break
+						}
+					}
+					if !next {
+						// Dead end; this can't happen on a thin skeleton
+						break
+					}
+				}
+			}
+		}
+	}
+
+	return g
+}
+
+// Prune removes the spurs of a skeleton, i.e. the branches between an
+// endpoint and a junction, that are shorter than the given number of pixels.
+// It returns the image, which is modified in-place.
+//
+// Spurs that only appear once others have been removed are kept; call Prune
+// again to remove them.
+func (b *BinaryImage) Prune(length int) *BinaryImage {
+	g := NewSkeletonGraph(b)
+
+	for _, e := range g.Edges {
+		from, to := g.Nodes[e.From], g.Nodes[e.To]
+		if from.Junction == to.Junction {
+			continue
+		}
+
+		// the branch includes its endpoint
+		if len(e.Pixels)+1 >= length {
+			continue
+		}
+
+		endpoint := from
+		if from.Junction {
+			endpoint = to
+		}
+
+		b.Set(endpoint.X, endpoint.Y, false)
+		for _, p := range e.Pixels {
+			b.Set(p.X, p.Y, false)
+		}
+	}
+
+	return b
+}
@@ -0,0 +1,129 @@
+package leonard
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+// bar returns a w×h image with a white rectangle of the given thickness in
+// its middle, with a 2-pixel margin.
+func bar(w, h int) *BinaryImage {
+	b := NewEmptyBinaryImage(h+4, w+4)
+	for y := 2; y < h+2; y++ {
+		for x := 2; x < w+2; x++ {
+			b.Set(x, y, true)
+		}
+	}
+	return b
+}
+
+func TestThin(t *testing.T) {
+	for _, alg := range []ThinningAlgorithm{ZhangSuen, ZhangWang, GuoHall, MedialAxis} {
+		orig := bar(21, 5)
+		skeleton := orig.Clone().Thin(alg)
+
+		points := skeleton.Points()
+		if len(points) == 0 {
+			t.Errorf("algorithm %d: empty skeleton", alg)
+			continue
+		}
+		for _, p := range points {
+			if !orig.Get(p.X, p.Y) {
+				t.Errorf("algorithm %d: %v isn't part of the shape", alg, p)
+			}
+			// the skeleton is one pixel thick
+			if skeleton.Get(p.X+1, p.Y) && skeleton.Get(p.X, p.Y+1) && skeleton.Get(p.X+1, p.Y+1) {
+				t.Errorf("algorithm %d: 2x2 block at %v", alg, p)
+			}
+		}
+		if n := skeleton.ConnectedComponents(Connectivity8).MaxLabel(); n != 1 {
+			t.Errorf("algorithm %d: got %d components, want 1", alg, n)
+		}
+	}
+}
+
+func TestThinKeepsLines(t *testing.T) {
+	line := newBinaryImage(
+		"..........",
+		".#######..",
+		"..........",
+	)
+
+	for _, alg := range []ThinningAlgorithm{ZhangWang, GuoHall, MedialAxis} {
+		skeleton := line.Clone().Thin(alg)
+		if got := len(skeleton.Points()); got != 7 {
+			t.Errorf("algorithm %d: got %d pixels, want 7", alg, got)
+		}
+	}
+}
+
+func TestMedialAxisTransform(t *testing.T) {
+	b := bar(21, 5)
+	skeleton, distances := b.MedialAxisTransform()
+
+	// the middle row is 3 pixels away from the outside ones
+	for x := 6; x < 19; x++ {
+		if !skeleton.Get(x, 4) {
+			t.Errorf("(%d, 4) isn't part of the medial axis", x)
+		}
+		if d := distances.FloatAt(x, 4, 0); math.Abs(float64(d)-3) > 1e-6 {
+			t.Errorf("got a distance of %f at (%d, 4), want 3", d, x)
+		}
+	}
+
+	if d := distances.FloatAt(4, 2, 0); d != 0 {
+		t.Errorf("got a distance of %f outside of the axis, want 0", d)
+	}
+	if len(b.Points()) != 21*5 {
+		t.Error("the image was modified")
+	}
+}
+
+func TestSkeletonGraph(t *testing.T) {
+	cross := newBinaryImage(
+		"....#....",
+		"....#....",
+		"....#....",
+		"#########",
+		"....#....",
+		"....#....",
+	)
+	g := NewSkeletonGraph(cross)
+
+	if got := g.Junctions(); len(got) != 1 {
+		t.Errorf("got junctions %v, want 1", got)
+	}
+	want := []image.Point{{4, 0}, {0, 3}, {8, 3}, {4, 5}}
+	got := g.Endpoints()
+	if len(got) != len(want) {
+		t.Fatalf("got endpoints %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got endpoints %v, want %v", got, want)
+		}
+	}
+	if len(g.Edges) != 4 {
+		t.Errorf("got %d edges, want 4", len(g.Edges))
+	}
+}
+
+func TestPrune(t *testing.T) {
+	spur := newBinaryImage(
+		"...........",
+		".....#.....",
+		".#########.",
+		"...........",
+	)
+
+	pruned := spur.Clone().Prune(2)
+	if pruned.Get(5, 1) {
+		t.Error("the spur wasn't removed")
+	}
+
+	// with a length of 1 nothing is shorter than the spur
+	if kept := spur.Clone().Prune(1); !kept.Get(5, 1) {
+		t.Error("the spur was removed")
+	}
+}
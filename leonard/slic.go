@@ -0,0 +1,201 @@
+package leonard
+
+import (
+	"image"
+	"math"
+)
+
+// slicIterations is the number of k-means iterations of SLIC. The original
+// paper finds that 10 are enough for most images.
+const slicIterations = 10
+
+// slicCenter is the center of a superpixel: its mean color in the Lab space
+// and its position.
+type slicCenter struct {
+	l, a, b float64
+	x, y    float64
+}
+
+// SLIC segments an image in about the given number of superpixels, i.e. small
+// regions of similar colors. The compactness weights the distance between
+// pixels against the difference of their colors: higher values give more
+// regular superpixels and lower values superpixels that stick more to the
+// edges of the image. 10 is a good start.
+//
+// It returns a label image where each superpixel has its own label, from 1.
+func SLIC(img image.Image, segments int, compactness float64) *LabelImage {
+	// Achanta et al., "SLIC Superpixels Compared to State-of-the-art
+	// Superpixel Methods" (2012)
+	// See https://www.iro.umontreal.ca/~mignotte/IFT6150/Articles/SLIC_Superpixels.pdf
+	if segments < 1 {
+		panic("SLIC: the number of segments must be positive")
+	}
+
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+
+	// Colors are compared in the Lab space, where Euclidean distances match
+	// the perceived ones.
+	lab := NewFloatImage(bounds, 3)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			i := lab.PixOffset(x, y)
+			l, a, b := rgbToLab(img.At(x, y))
+			lab.Pix[i], lab.Pix[i+1], lab.Pix[i+2] = float32(l), float32(a), float32(b)
+		}
+	}
+
+	// color at (x, y), relative to the top-left corner of the image
+	at := func(x, y int) (float64, float64, float64) {
+		i := (y*w + x) * 3
+		return float64(lab.Pix[i]), float64(lab.Pix[i+1]), float64(lab.Pix[i+2])
+	}
+
+	step := int(math.Sqrt(float64(w*h)/float64(segments)) + 0.5)
+	if step < 1 {
+		step = 1
+	}
+
+	// gradient returns the squared norm of the color gradient at (x, y)
+	gradient := func(x, y int) float64 {
+		if x < 1 || y < 1 || x >= w-1 || y >= h-1 {
+			return math.Inf(1)
+		}
+		l1, a1, b1 := at(x+1, y)
+		l2, a2, b2 := at(x-1, y)
+		l3, a3, b3 := at(x, y+1)
+		l4, a4, b4 := at(x, y-1)
+		return (l1-l2)*(l1-l2) + (a1-a2)*(a1-a2) + (b1-b2)*(b1-b2) +
+			(l3-l4)*(l3-l4) + (a3-a4)*(a3-a4) + (b3-b4)*(b3-b4)
+	}
+
+	// Start with centers on a regular grid, moved to the lowest gradient of
+	// their 3x3 neighbourhood so that they're not on an edge.
+	var centers []slicCenter
+	for y := step / 2; y < h; y += step {
+		for x := step / 2; x < w; x += step {
+			cx, cy := x, y
+			best := gradient(x, y)
+			for _, o := range clockwiseOffsets {
+				nx, ny := o.apply(x, y)
+				if g := gradient(nx, ny); g < best {
+					best, cx, cy = g, nx, ny
+				}
+			}
+			l, a, b := at(cx, cy)
+			centers = append(centers, slicCenter{l, a, b, float64(cx), float64(cy)})
+		}
+	}
+
+	// index of the closest center of each pixel
+	labels := make([]int, w*h)
+	distances := make([]float64, w*h)
+
+	// weight of the spatial distance against the color one
+	weight := (compactness * compactness) / float64(step*step)
+
+	for iter := 0; iter < slicIterations; iter++ {
+		for i := range distances {
+			distances[i] = math.Inf(1)
+			labels[i] = -1
+		}
+
+		// Each center only looks for pixels in a 2S×2S window around it
+		for k, c := range centers {
+			x0, x1 := clampInt(int(c.x)-step, 0, w), clampInt(int(c.x)+step+1, 0, w)
+			y0, y1 := clampInt(int(c.y)-step, 0, h), clampInt(int(c.y)+step+1, 0, h)
+
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					l, a, b := at(x, y)
+					dc := (l-c.l)*(l-c.l) + (a-c.a)*(a-c.a) + (b-c.b)*(b-c.b)
+					ds := (float64(x)-c.x)*(float64(x)-c.x) + (float64(y)-c.y)*(float64(y)-c.y)
+
+					i := y*w + x
+					if d := dc + ds*weight; d < distances[i] {
+						distances[i] = d
+						labels[i] = k
+					}
+				}
+			}
+		}
+
+		// Move the centers to the mean of their pixels
+		sums := make([]slicCenter, len(centers))
+		counts := make([]int, len(centers))
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				k := labels[y*w+x]
+				if k < 0 {
+					continue
+				}
+				l, a, b := at(x, y)
+				s := &sums[k]
+				s.l, s.a, s.b = s.l+l, s.a+a, s.b+b
+				s.x, s.y = s.x+float64(x), s.y+float64(y)
+				counts[k]++
+			}
+		}
+		for k, s := range sums {
+			if n := float64(counts[k]); n > 0 {
+				centers[k] = slicCenter{s.l / n, s.a / n, s.b / n, s.x / n, s.y / n}
+			}
+		}
+	}
+
+	return slicConnectivity(labels, w, h, bounds, step*step/4)
+}
+
+// slicConnectivity relabels the superpixels found by SLIC so that each one is
+// connected: the groups of pixels smaller than minSize are merged with a
+// neighbouring superpixel.
+func slicConnectivity(labels []int, w, h int, bounds image.Rectangle, minSize int) *LabelImage {
+	out := NewLabelImage(bounds)
+	offsets := Connectivity4.offsets()
+
+	next := 1
+	for start := range labels {
+		if out.Labels[start] != 0 {
+			continue
+		}
+
+		sx, sy := start%w, start/w
+
+		// Label of a neighbour of the first pixel that's already been
+		// relabelled; it's on the left or above.
+		adjacent := 0
+		for _, o := range offsets {
+			nx, ny := o.apply(sx, sy)
+			if nx >= 0 && ny >= 0 && nx < w && ny < h && out.Labels[ny*w+nx] != 0 {
+				adjacent = out.Labels[ny*w+nx]
+			}
+		}
+
+		component := []int{start}
+		out.Labels[start] = next
+		for k := 0; k < len(component); k++ {
+			x, y := component[k]%w, component[k]/w
+			for _, o := range offsets {
+				nx, ny := o.apply(x, y)
+				if nx < 0 || ny < 0 || nx >= w || ny >= h {
+					continue
+				}
+				i := ny*w + nx
+				if out.Labels[i] == 0 && labels[i] == labels[start] {
+					out.Labels[i] = next
+					component = append(component, i)
+				}
+			}
+		}
+
+		if len(component) < minSize && adjacent != 0 {
+			for _, i := range component {
+				out.Labels[i] = adjacent
+			}
+		} else {
+			next++
+		}
+	}
+
+	return out
+}
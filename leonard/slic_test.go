@@ -0,0 +1,83 @@
+package leonard
+
+import (
+	"image"
+	"testing"
+)
+
+func TestSLIC(t *testing.T) {
+	// two halves of very different colors
+	img := newGrayImage(40, 40, func(x, y int) uint8 {
+		if x < 20 {
+			return 30
+		}
+		return 220
+	})
+	labels := SLIC(img, 16, 10)
+
+	n := labels.MaxLabel()
+	if n < 8 || n > 32 {
+		t.Errorf("got %d superpixels, want about 16", n)
+	}
+
+	halves := make(map[int][2]bool)
+	sizes := make(map[int]int)
+	for y := 0; y < 40; y++ {
+		for x := 0; x < 40; x++ {
+			label := labels.Label(x, y)
+			if label <= 0 {
+				t.Fatalf("(%d, %d) isn't labelled", x, y)
+			}
+			h := halves[label]
+			h[x/20] = true
+			halves[label] = h
+			sizes[label]++
+		}
+	}
+
+	for label, h := range halves {
+		if h[0] && h[1] {
+			t.Errorf("superpixel %d spans both halves", label)
+		}
+	}
+
+	// each superpixel is connected
+	for label := range sizes {
+		b := NewEmptyBinaryImage(40, 40)
+		for y := 0; y < 40; y++ {
+			for x := 0; x < 40; x++ {
+				if labels.Label(x, y) == label {
+					b.Set(x, y, true)
+				}
+			}
+		}
+		if got := b.ConnectedComponents(Connectivity4).MaxLabel(); got != 1 {
+			t.Errorf("superpixel %d has %d parts", label, got)
+		}
+	}
+}
+
+func TestSLICSingleSegment(t *testing.T) {
+	labels := SLIC(uniformGray(10, 6, 100), 1, 10)
+	for i, label := range labels.Labels {
+		if label != 1 {
+			t.Fatalf("got label %d at %d, want 1", label, i)
+		}
+	}
+}
+
+func TestSLICEmptyImage(t *testing.T) {
+	labels := SLIC(image.NewGray(image.Rect(0, 0, 0, 0)), 4, 10)
+	if len(labels.Labels) != 0 {
+		t.Errorf("got %d labels, want none", len(labels.Labels))
+	}
+}
+
+func TestSLICInvalidSegments(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic")
+		}
+	}()
+	SLIC(uniformGray(4, 4, 0), 0, 10)
+}
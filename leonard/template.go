@@ -0,0 +1,348 @@
+package leonard
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"math/cmplx"
+)
+
+// TemplateMatchMethod is the measure of how much a template matches a part of
+// an image.
+type TemplateMatchMethod int
+
+const (
+	// SSD is the mean of the squared differences of luminance between the
+	// template and the image, between 0 and 1. Lower is better. It's
+	// sensitive to changes of brightness and contrast.
+	SSD TemplateMatchMethod = iota
+	// NCC is the normalized cross-correlation between the template and the
+	// image, between 0 and 1. Higher is better. It isn't sensitive to
+	// changes of contrast.
+	NCC
+	// ZNCC is the zero-mean normalized cross-correlation, between -1 and 1.
+	// Higher is better. It isn't sensitive to changes of brightness nor of
+	// contrast, but it's undefined on flat areas, where it's 0.
+	ZNCC
+)
+
+// integralImage holds the sums of the values of an image over all the
+// rectangles that start at its top-left corner, so that the sum over any
+// rectangle can be computed in constant time.
+type integralImage struct {
+	// sums has one more row and column than the image
+	sums   []float64
+	stride int
+}
+
+func newIntegralImage(values []float64, w, h int) *integralImage {
+	ii := &integralImage{
+		sums:   make([]float64, (w+1)*(h+1)),
+		stride: w + 1,
+	}
+	for y := 0; y < h; y++ {
+		row := 0.0
+		for x := 0; x < w; x++ {
+			row += values[y*w+x]
+			ii.sums[(y+1)*ii.stride+x+1] = ii.sums[y*ii.stride+x+1] + row
+		}
+	}
+	return ii
+}
+
+// sum returns the sum of the values in the rectangle of size w×h whose
+// top-left corner is at (x, y).
+func (ii *integralImage) sum(x, y, w, h int) float64 {
+	s := ii.sums
+	return s[(y+h)*ii.stride+x+w] - s[y*ii.stride+x+w] - s[(y+h)*ii.stride+x] + s[y*ii.stride+x]
+}
+
+// fft computes in place the discrete Fourier transform of a, or its inverse,
+// unnormalized. The length of a must be a power of 2.
+func fft(a []complex128, inverse bool) {
+	// Iterative Cooley-Tukey
+	n := len(a)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			a[i], a[j] = a[j], a[i]
+		}
+	}
+
+	for length := 2; length <= n; length <<= 1 {
+		angle := 2 * math.Pi / float64(length)
+		if !inverse {
+			angle = -angle
+		}
+		w := cmplx.Rect(1, angle)
+		for start := 0; start < n; start += length {
+			wk := complex(1, 0)
+			for k := 0; k < length/2; k++ {
+				u, v := a[start+k], a[start+k+length/2]*wk
+				a[start+k], a[start+k+length/2] = u+v, u-v
+				wk *= w
+			}
+		}
+	}
+}
+
+// fft2D computes in place the 2D discrete Fourier transform of a, an n×m
+// matrix stored row by row, or its inverse, unnormalized.
+func fft2D(a []complex128, n, m int, inverse bool) {
+	for y := 0; y < m; y++ {
+		fft(a[y*n:(y+1)*n], inverse)
+	}
+	column := make([]complex128, m)
+	for x := 0; x < n; x++ {
+		for y := range column {
+			column[y] = a[y*n+x]
+		}
+		fft(column, inverse)
+		for y, v := range column {
+			a[y*n+x] = v
+		}
+	}
+}
+
+// nextPowerOf2 returns the smallest power of 2 that's at least n
+func nextPowerOf2(n int) int {
+	p := 1
+	for p < n {
+		p <<= 1
+	}
+	return p
+}
+
+// crossCorrelation returns the sum of the products of the pixels of the
+// template and the ones of the image below it, for each position of the
+// template where it's entirely in the image. Both are given row by row.
+func crossCorrelation(img []float64, w, h int, tmpl []float64, tw, th int) []float64 {
+	rw, rh := w-tw+1, h-th+1
+	out := make([]float64, rw*rh)
+
+	// The direct computation is faster for small templates. The cost of the
+	// FFT is an estimate of the number of operations of its three
+	// transforms.
+	n, m := nextPowerOf2(w), nextPowerOf2(h)
+	directCost := float64(rw * rh * tw * th)
+	fftCost := 15 * float64(n*m) * math.Log2(float64(n*m))
+
+	if directCost <= fftCost {
+		for y := 0; y < rh; y++ {
+			for x := 0; x < rw; x++ {
+				var s float64
+				for ty := 0; ty < th; ty++ {
+					row := img[(y+ty)*w+x : (y+ty)*w+x+tw]
+					for tx, v := range tmpl[ty*tw : (ty+1)*tw] {
+						s += v * row[tx]
+					}
+				}
+				out[y*rw+x] = s
+			}
+		}
+		return out
+	}
+
+	// The correlation is the inverse transform of F(img)·conj(F(tmpl)). The
+	// transforms are circular, but the positions we want don't wrap around
+	// since the padded size is at least the size of the image.
+	fi := make([]complex128, n*m)
+	ft := make([]complex128, n*m)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			fi[y*n+x] = complex(img[y*w+x], 0)
+		}
+	}
+	for y := 0; y < th; y++ {
+		for x := 0; x < tw; x++ {
+			ft[y*n+x] = complex(tmpl[y*tw+x], 0)
+		}
+	}
+
+	fft2D(fi, n, m, false)
+	fft2D(ft, n, m, false)
+	for i := range fi {
+		fi[i] *= cmplx.Conj(ft[i])
+	}
+	fft2D(fi, n, m, true)
+
+	scale := float64(n * m)
+	for y := 0; y < rh; y++ {
+		for x := 0; x < rw; x++ {
+			out[y*rw+x] = real(fi[y*n+x]) / scale
+		}
+	}
+	return out
+}
+
+// luminanceValues returns the luminance of the pixels of an image, between 0
+// and 1, row by row.
+func luminanceValues(img image.Image) []float64 {
+	lum := NewFloatImageFrom(img, 1)
+	values := make([]float64, len(lum.Pix))
+	for i, v := range lum.Pix {
+		values[i] = float64(v)
+	}
+	return values
+}
+
+// TemplateMatches holds the scores of a template at each position of an image
+type TemplateMatches struct {
+	// Scores holds the score of the template placed with its top-left corner
+	// at each pixel. It's smaller than the image since the template must be
+	// entirely in it.
+	Scores *FloatImage
+	Method TemplateMatchMethod
+	// Size is the size of the template
+	Size image.Point
+}
+
+// MatchTemplate computes how much a template, e.g. a logo, matches each part
+// of an image with the given method. Images are compared by their luminance.
+func MatchTemplate(img, tmpl image.Image, method TemplateMatchMethod) *TemplateMatches {
+	if method != SSD && method != NCC && method != ZNCC {
+		panic("Invalid template matching method")
+	}
+
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	tw, th := tmpl.Bounds().Dx(), tmpl.Bounds().Dy()
+	if tw > w || th > h {
+		panic("MatchTemplate: the template is larger than the image")
+	}
+	if tw == 0 || th == 0 {
+		panic("MatchTemplate: the template is empty")
+	}
+
+	values := luminanceValues(img)
+	t := luminanceValues(tmpl)
+	n := float64(tw * th)
+
+	var tSum, tSquares float64
+	for _, v := range t {
+		tSum += v
+		tSquares += v * v
+	}
+
+	if method == ZNCC {
+		// Correlating with the zero-mean template is the same as correlating
+		// the zero-mean parts of the image with it, since the sum of its
+		// values is 0.
+		mean := tSum / n
+		tSquares = 0
+		for i, v := range t {
+			t[i] = v - mean
+			tSquares += t[i] * t[i]
+		}
+	}
+
+	squares := make([]float64, len(values))
+	for i, v := range values {
+		squares[i] = v * v
+	}
+	sums, sumsOfSquares := newIntegralImage(values, w, h), newIntegralImage(squares, w, h)
+
+	cross := crossCorrelation(values, w, h, t, tw, th)
+
+	rw, rh := w-tw+1, h-th+1
+	scores := NewFloatImage(image.Rectangle{bounds.Min, bounds.Min.Add(image.Pt(rw, rh))}, 1)
+
+	for y := 0; y < rh; y++ {
+		for x := 0; x < rw; x++ {
+			i := y*rw + x
+			iSquares := sumsOfSquares.sum(x, y, tw, th)
+
+			var score float64
+			switch method {
+			case SSD:
+				score = (iSquares - 2*cross[i] + tSquares) / n
+				// rounding errors can make it slightly negative
+				score = math.Max(score, 0)
+			case NCC:
+				if d := math.Sqrt(iSquares * tSquares); d > 1e-9 {
+					score = cross[i] / d
+				}
+			case ZNCC:
+				s := sums.sum(x, y, tw, th)
+				if d := math.Sqrt((iSquares - s*s/n) * tSquares); d > 1e-9 {
+					score = cross[i] / d
+				}
+			}
+			scores.Pix[i] = float32(score)
+		}
+	}
+
+	return &TemplateMatches{Scores: scores, Method: method, Size: image.Pt(tw, th)}
+}
+
+// Best returns the positions of the top-left corner of the best matches,
+// best first. The score of a match must be at least threshold, or at most
+// threshold with SSD; it's the only cutoff, so a negative threshold with ZNCC
+// gives matches with negative scores. A match is dropped if a better one is at
+// most r pixels away from it on both axes, where r is half of the smallest
+// side of the template but at least 1. max is the maximum number of matches;
+// 0 means no limit.
+func (m *TemplateMatches) Best(threshold float64, max int) []Keypoint {
+	radius := m.Size.X
+	if m.Size.Y < radius {
+		radius = m.Size.Y
+	}
+	radius /= 2
+	if radius < 1 {
+		radius = 1
+	}
+
+	scores := m.Scores
+	if m.Method == SSD {
+		// Lower is better: flip the scores so that the best matches are
+		// maxima.
+		scores = scores.Clone()
+		for i, v := range scores.Pix {
+			scores.Pix[i] = 1 - v
+		}
+		threshold = 1 - threshold
+	}
+
+	matches := localMaxima(scores, threshold, radius)
+	if max > 0 && len(matches) > max {
+		matches = matches[:max]
+	}
+	if m.Method == SSD {
+		for i := range matches {
+			matches[i].Score = 1 - matches[i].Score
+		}
+	}
+	return matches
+}
+
+// Rect returns the rectangle covered by the template at a match
+func (m *TemplateMatches) Rect(match Keypoint) image.Rectangle {
+	p := image.Pt(match.X, match.Y)
+	return image.Rectangle{p, p.Add(m.Size)}
+}
+
+// DrawRectangles draws the outline of some rectangles with the given color
+// over an image.
+func DrawRectangles(img image.Image, rects []image.Rectangle, c color.Color) image.Image {
+	bounds := img.Bounds()
+	out := newColorImageLike(bounds, img)
+	draw.Draw(out, bounds, img, bounds.Min, draw.Src)
+
+	for _, r := range rects {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			out.Set(x, r.Min.Y, c)
+			out.Set(x, r.Max.Y-1, c)
+		}
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			out.Set(r.Min.X, y, c)
+			out.Set(r.Max.X-1, y, c)
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,104 @@
+package leonard
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+func TestMatchTemplate(t *testing.T) {
+	img := blocks(24, 16, 3)
+	tmpl := img.SubImage(image.Rect(9, 5, 15, 10))
+
+	for _, c := range []struct {
+		method TemplateMatchMethod
+		best   float32
+	}{
+		{SSD, 0},
+		{NCC, 1},
+		{ZNCC, 1},
+	} {
+		m := MatchTemplate(img, tmpl, c.method)
+
+		if size := m.Scores.Rect.Size(); size != image.Pt(19, 12) {
+			t.Errorf("%d: got scores of size %v, want 19x12", c.method, size)
+		}
+		if v := m.Scores.FloatAt(9, 5, 0); math.Abs(float64(v-c.best)) > 1e-4 {
+			t.Errorf("%d: got %g at the template, want %g", c.method, v, c.best)
+		}
+
+		best := m.Best(float64(c.best), 0)
+		if len(best) != 1 || best[0].X != 9 || best[0].Y != 5 {
+			t.Errorf("%d: got %v, want the template", c.method, best)
+		}
+		if r := m.Rect(best[0]); r != image.Rect(9, 5, 15, 10) {
+			t.Errorf("%d: got rectangle %v", c.method, r)
+		}
+	}
+}
+
+func TestMatchTemplateFFT(t *testing.T) {
+	// The template is large enough for the correlation to use the FFT
+	img := blocks(64, 64, 4)
+	tmpl := img.SubImage(image.Rect(10, 20, 42, 52))
+
+	best := MatchTemplate(img, tmpl, SSD).Best(1e-6, 0)
+	if len(best) != 1 || best[0].X != 10 || best[0].Y != 20 || best[0].Score > 1e-6 {
+		t.Errorf("got %v, want the template", best)
+	}
+}
+
+func TestMatchTemplateNegativeZNCC(t *testing.T) {
+	// the image is the template reversed
+	img := newGrayImage(3, 1, func(x, _ int) uint8 { return uint8(255 - 127*x) })
+	tmpl := newGrayImage(3, 1, func(x, _ int) uint8 { return uint8(1 + 127*x) })
+	m := MatchTemplate(img, tmpl, ZNCC)
+
+	best := m.Best(-1, 0)
+	if len(best) != 1 || math.Abs(best[0].Score+1) > 1e-4 {
+		t.Errorf("got %v, want a match of -1", best)
+	}
+	if best := m.Best(-0.5, 0); len(best) != 0 {
+		t.Errorf("got %v with a threshold of -0.5, want no match", best)
+	}
+}
+
+func TestMatchTemplateBestRadius(t *testing.T) {
+	// two copies of the template, 3 pixels apart
+	row := []uint8{0, 0, 255, 0, 0, 255, 0, 0, 0, 0}
+	img := newGrayImage(len(row), 8, func(x, _ int) uint8 { return row[x] })
+	tmpl := newGrayImage(3, 8, func(x, _ int) uint8 { return row[x+1] })
+
+	// the radius is 1 with a 3x8 template
+	m := MatchTemplate(img, tmpl, SSD)
+	if best := m.Best(0, 0); len(best) != 2 || best[0].X != 1 || best[1].X != 4 {
+		t.Errorf("got %v, want matches at x=1 and x=4", best)
+	}
+	if best := m.Best(0, 1); len(best) != 1 {
+		t.Errorf("max=1: got %v, want 1 match", best)
+	}
+
+	// the radius is 3 with an 8x8 template
+	m.Size = image.Pt(8, 8)
+	if best := m.Best(0, 0); len(best) != 1 || best[0].X != 1 {
+		t.Errorf("got %v, want a match at x=1", best)
+	}
+}
+
+func TestMatchTemplatePanics(t *testing.T) {
+	img := uniformGray(4, 4, 0)
+	for name, f := range map[string]func(){
+		"larger template": func() { MatchTemplate(img, uniformGray(5, 2, 0), SSD) },
+		"empty template":  func() { MatchTemplate(img, uniformGray(0, 2, 0), SSD) },
+		"invalid method":  func() { MatchTemplate(img, img, TemplateMatchMethod(42)) },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected a panic", name)
+				}
+			}()
+			f()
+		}()
+	}
+}
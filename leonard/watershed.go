@@ -0,0 +1,242 @@
+package leonard
+
+import (
+	"container/heap"
+	"fmt"
+	"image"
+)
+
+// floodItem is a pixel waiting to be flooded
+type floodItem struct {
+	p     image.Point
+	level float32
+	// insertion order, used to flood plateaus from their borders
+	order int
+}
+
+// floodQueue is a priority queue of pixels ordered by level
+type floodQueue []floodItem
+
+func (q floodQueue) Len() int { return len(q) }
+func (q floodQueue) Less(i, j int) bool {
+	if q[i].level != q[j].level {
+		return q[i].level < q[j].level
+	}
+	return q[i].order < q[j].order
+}
+func (q floodQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *floodQueue) Push(x interface{}) { *q = append(*q, x.(floodItem)) }
+func (q *floodQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+// Watershed segments an image, usually the magnitude of its gradients, by
+// flooding it from the given markers: each labelled pixel of markers is the
+// source of a region with the same label that grows over the darkest pixels
+// first. Pixels where two regions meet are labelled WatershedLine; pixels that
+// can't be reached from any marker are left unlabelled.
+//
+// The markers must have the same bounds as the image, otherwise an error is
+// returned. They're not modified.
+func Watershed(img image.Image, markers *LabelImage) (*LabelImage, error) {
+	// Meyer's flooding algorithm; see e.g.
+	// https://en.wikipedia.org/wiki/Watershed_(image_processing)
+	bounds := img.Bounds()
+	if markers.Rect != bounds {
+		return nil, fmt.Errorf("watershed: the markers' bounds %v differ from the image's %v", markers.Rect, bounds)
+	}
+
+	lum := NewFloatImageFrom(img, 1)
+	offsets := Connectivity4.offsets()
+
+	labels := &LabelImage{
+		Labels: append([]int(nil), markers.Labels...),
+		Rect:   bounds,
+	}
+	queued := make([]bool, len(labels.Labels))
+
+	q := &floodQueue{}
+	order := 0
+
+	// push the unlabelled neighbours of a pixel
+	pushNeighbours := func(p image.Point) {
+		for _, o := range offsets {
+			x, y := o.apply(p.X, p.Y)
+			if !(image.Point{x, y}.In(bounds)) {
+				continue
+			}
+			i := labels.index(x, y)
+			if labels.Labels[i] != 0 || queued[i] {
+				continue
+			}
+			queued[i] = true
+			heap.Push(q, floodItem{image.Point{x, y}, lum.FloatAt(x, y, 0), order})
+			order++
+		}
+	}
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if labels.Label(x, y) > 0 {
+				pushNeighbours(image.Point{x, y})
+			}
+		}
+	}
+
+	for q.Len() > 0 {
+		p := heap.Pop(q).(floodItem).p
+
+		label := 0
+		for _, o := range offsets {
+			l := labels.Label(o.apply(p.X, p.Y))
+			if l <= 0 {
+				continue
+			}
+			if label == 0 {
+				label = l
+			} else if l != label {
+				label = WatershedLine
+				break
+			}
+		}
+
+		labels.SetLabel(p.X, p.Y, label)
+		if label > 0 {
+			pushNeighbours(p)
+		}
+	}
+
+	return labels, nil
+}
+
+// RegionalMinima labels the regional minima of the luminance of an image, i.e.
+// the connected groups of pixels whose neighbours are all brighter.
+//
+// Minima shallower than depth, between 0 and 1, are ignored: their depth is
+// the difference between their lowest pixel and the lowest one they'd
+// overflow from if they were filled with water. The pixels of the other
+// minima that are less than depth above their lowest one are included in
+// them. A depth of 0 keeps all the minima.
+//
+// The minima of the gradients of an image can be used as markers for
+// Watershed.
+func RegionalMinima(img image.Image, depth float64) *LabelImage {
+	lum := NewFloatImageFrom(img, 1)
+	bounds := lum.Rect
+	offsets := Connectivity8.offsets()
+
+	levels := lum.Pix
+	if depth > 0 {
+		levels = fillMinima(lum, float32(depth), offsets)
+	}
+
+	labels := NewLabelImage(bounds)
+	// pixels already part of a plateau
+	visited := make([]bool, len(levels))
+
+	next := 1
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			i := labels.index(x, y)
+			if visited[i] {
+				continue
+			}
+
+			// Find the plateau of pixels with the same level as this one and
+			// check if it's a minimum.
+			level := levels[i]
+			minimum := true
+			plateau := []image.Point{{x, y}}
+			visited[i] = true
+
+			for k := 0; k < len(plateau); k++ {
+				p := plateau[k]
+				for _, o := range offsets {
+					nx, ny := o.apply(p.X, p.Y)
+					if !(image.Point{nx, ny}.In(bounds)) {
+						continue
+					}
+					j := labels.index(nx, ny)
+					if levels[j] < level {
+						minimum = false
+					} else if levels[j] == level && !visited[j] {
+						visited[j] = true
+						plateau = append(plateau, image.Point{nx, ny})
+					}
+				}
+			}
+
+			if minimum {
+				for _, p := range plateau {
+					labels.SetLabel(p.X, p.Y, next)
+				}
+				next++
+			}
+		}
+	}
+
+	return labels
+}
+
+// fillMinima returns the luminance of the pixels of an image with its minima
+// filled up to depth: this is the h-minima transform, the reconstruction by
+// erosion of lum+depth above lum. Its regional minima are the minima of the
+// image that are at least depth deep.
+func fillMinima(lum *FloatImage, depth float32, offsets []offset) []float32 {
+	// Vincent, "Morphological grayscale reconstruction in image analysis:
+	// applications and efficient algorithms" (1993). Each pixel is lowered to
+	// the highest of its luminance and the level of its lowest neighbour,
+	// lowest pixels first.
+	bounds := lum.Rect
+	levels := make([]float32, len(lum.Pix))
+
+	q := make(floodQueue, 0, len(levels))
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			i := lum.PixOffset(x, y)
+			levels[i] = lum.Pix[i] + depth
+			q = append(q, floodItem{image.Point{x, y}, levels[i], len(q)})
+		}
+	}
+	heap.Init(&q)
+	order := len(q)
+
+	for q.Len() > 0 {
+		item := heap.Pop(&q).(floodItem)
+		i := lum.PixOffset(item.p.X, item.p.Y)
+		if item.level > levels[i] {
+			// the pixel was lowered after being queued
+			continue
+		}
+
+		for _, o := range offsets {
+			x, y := o.apply(item.p.X, item.p.Y)
+			if !(image.Point{x, y}.In(bounds)) {
+				continue
+			}
+			j := lum.PixOffset(x, y)
+			level := levels[i]
+			if lum.Pix[j] > level {
+				level = lum.Pix[j]
+			}
+			if level < levels[j] {
+				levels[j] = level
+				heap.Push(&q, floodItem{image.Point{x, y}, level, order})
+				order++
+			}
+		}
+	}
+
+	return levels
+}
+
+// AutoWatershed segments an image with Watershed, using the RegionalMinima of
+// the image with the given depth as markers.
+func AutoWatershed(img image.Image, depth float64) *LabelImage {
+	// the minima have the bounds of the image
+	labels, _ := Watershed(img, RegionalMinima(img, depth))
+	return labels
+}
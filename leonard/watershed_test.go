@@ -0,0 +1,124 @@
+package leonard
+
+import (
+	"image"
+	"testing"
+)
+
+// twoBasins returns a 9×5 image with two dark basins separated by a bright
+// vertical ridge on x=4.
+func twoBasins() *image.Gray {
+	return newGrayImage(9, 5, func(x, y int) uint8 {
+		// the basins are at x=2 and x=6
+		return uint8(40 + 50*absint(absint(x-4)-2))
+	})
+}
+
+func TestWatershed(t *testing.T) {
+	img := twoBasins()
+
+	markers := NewLabelImage(img.Bounds())
+	markers.SetLabel(2, 2, 1)
+	markers.SetLabel(6, 2, 2)
+
+	labels, err := Watershed(img, markers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for y := 0; y < 5; y++ {
+		for x := 0; x < 9; x++ {
+			want := 1
+			if x == 4 {
+				want = WatershedLine
+			} else if x > 4 {
+				want = 2
+			}
+			if got := labels.Label(x, y); got != want {
+				t.Errorf("got label %d at (%d, %d), want %d", got, x, y, want)
+			}
+		}
+	}
+
+	if markers.MaxLabel() != 2 || markers.Label(0, 0) != 0 {
+		t.Error("the markers were modified")
+	}
+}
+
+func TestWatershedUnreachable(t *testing.T) {
+	img := twoBasins()
+	labels, err := Watershed(img, NewLabelImage(img.Bounds()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if labels.MaxLabel() != 0 {
+		t.Error("got labels without markers")
+	}
+}
+
+func TestWatershedBoundsMismatch(t *testing.T) {
+	img := twoBasins()
+
+	for _, r := range []image.Rectangle{
+		image.Rect(0, 0, 8, 5),
+		image.Rect(1, 0, 10, 5),
+	} {
+		if _, err := Watershed(img, NewLabelImage(r)); err == nil {
+			t.Errorf("%v: expected an error", r)
+		}
+	}
+}
+
+func TestAutoWatershed(t *testing.T) {
+	labels := AutoWatershed(twoBasins(), 0)
+
+	if got := labels.MaxLabel(); got != 2 {
+		t.Fatalf("got %d regions, want 2", got)
+	}
+	if labels.Label(0, 0) == labels.Label(8, 4) {
+		t.Error("the basins have the same label")
+	}
+	if got := labels.Label(4, 2); got != WatershedLine {
+		t.Errorf("got label %d on the ridge, want a watershed line", got)
+	}
+}
+
+func TestRegionalMinima(t *testing.T) {
+	// A shallow minimum on x=1 that overflows on x=2 in a deeper one on x=3
+	row := []uint8{200, 100, 150, 20, 150, 200}
+	img := newGrayImage(len(row), 3, func(x, y int) uint8 { return row[x] })
+
+	for _, tt := range []struct {
+		depth float64
+		want  []int
+	}{
+		{0, []int{0, 1, 0, 2, 0, 0}},
+		{40. / 255, []int{0, 1, 0, 2, 0, 0}},
+		// the shallow minimum is 50 deep
+		{60. / 255, []int{0, 0, 0, 1, 0, 0}},
+		// the pixels less than depth above the bottom are part of the minimum
+		{140. / 255, []int{0, 1, 1, 1, 1, 0}},
+	} {
+		minima := RegionalMinima(img, tt.depth)
+		for y := 0; y < 3; y++ {
+			for x, want := range tt.want {
+				if got := minima.Label(x, y); got != want {
+					t.Errorf("depth %f: got label %d at (%d, %d), want %d", tt.depth, got, x, y, want)
+				}
+			}
+		}
+	}
+}
+
+func TestRegionalMinimaDeepPlateaus(t *testing.T) {
+	// Two minima far apart in luminance but both deep enough are kept,
+	// whatever the depth.
+	row := []uint8{250, 10, 250, 120, 250}
+	img := newGrayImage(len(row), 1, func(x, y int) uint8 { return row[x] })
+
+	for _, depth := range []float64{0.05, 0.2, 0.4} {
+		if got := RegionalMinima(img, depth).MaxLabel(); got != 2 {
+			t.Errorf("depth %f: got %d minima, want 2", depth, got)
+		}
+	}
+}
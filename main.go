@@ -2,59 +2,39 @@ package main
 
 import (
 	"fmt"
-	"image"
 	"os"
+	"runtime"
 
 	"github.com/bfontaine/leonard/leonard"
 	"gopkg.in/urfave/cli.v1"
 )
 
-var transformFuncs = map[string]func(image.Image) image.Image{
-	"gray":       leonard.Grayscale,
-	"binary":     leonard.Binary,
-	"vgradients": leonard.VerticalGradients,
-	"hgradients": leonard.HorizontalGradients,
-	"gradients":  leonard.Gradients,
-	"downscale":  leonard.Downscale,
-	"blur": func(i image.Image) image.Image {
-		return leonard.GaussianFilter(i, 1.4)
-	},
-	"edges": func(i image.Image) image.Image {
-		b := leonard.NewBinaryImage(
-			leonard.Gradients(leonard.GaussianFilter(i, 5.0)), -1)
-
-		b.ThinEdges()
-
-		// acc := b.HoughTransform()
-		// b.DrawLines(acc)
-
-		return b
-	},
-}
-
 func main() {
 	app := cli.NewApp()
 	app.Name = "Leonard"
 	app.Usage = "Apply various transforms on images"
-	app.UsageText = "leonard [options] <image> <output image>"
+	app.UsageText = "leonard [options] <image> <output image>\n" +
+		"   Use - to read the image from stdin or write it to stdout.\n" +
+		"   leonard run <pipeline> [<image> [<output image>]]\n" +
+		"   leonard batch [options] <image, directory or glob>...\n" +
+		"   leonard palette [--colors N] <image>"
 	// No "help" command, please. Unfortunately this also hides the flags.
 	app.HideHelp = true
 	app.Flags = []cli.Flag{
 		cli.StringSliceFlag{
 			Name:  "transform, t",
-			Usage: "Transformation to apply. You can chain them.",
+			Usage: "Transformation to apply: [label=]name[:param=value,...][@input,...]. You can chain them.",
 		},
 		cli.BoolFlag{
 			Name:  "list, l",
-			Usage: "List the available transformations",
+			Usage: "List the available transformations and their parameters",
 		},
 	}
+	app.Flags = append(app.Flags, outputFlags...)
 
 	app.Action = func(c *cli.Context) error {
 		if c.Bool("list") {
-			for name := range transformFuncs {
-				fmt.Println(name)
-			}
+			printTransforms()
 			return nil
 		}
 
@@ -65,26 +45,175 @@ func main() {
 			return cli.NewExitError("Please give me an output file.", 1)
 		}
 
-		img, err := leonard.LoadImage(c.Args().First())
-
+		// Parse all transforms before doing anything
+		p, err := pipelineFromSpecs(c.StringSlice("transform"))
 		if err != nil {
-			return cli.NewExitError(fmt.Sprintf("Decoding error: %s", err), 1)
+			return cli.NewExitError(err.Error(), 1)
 		}
 
-		for _, t := range c.StringSlice("transform") {
-			fn, ok := transformFuncs[t]
-			if !ok {
-				return cli.NewExitError(
-					fmt.Sprintf("Unknown transform '%s'", t), 1)
-			}
-			img = fn(img)
+		so, err := saveOptionsFromContext(c)
+		if err != nil {
+			return cli.NewExitError(err.Error(), 1)
 		}
 
-		if err := leonard.SaveImage(img, c.Args().Get(1)); err != nil {
-			return cli.NewExitError(fmt.Sprintf("Write error: %s", err), 1)
+		if err := p.run(c.Args().First(), c.Args().Get(1), so); err != nil {
+			return cli.NewExitError(err.Error(), 1)
 		}
 		return nil
 	}
 
+	app.Commands = []cli.Command{
+		{
+			Name:      "run",
+			Usage:     "Run a pipeline file (YAML or JSON)",
+			ArgsUsage: "<pipeline> [<image> [<output image>]]",
+			Flags:     outputFlags,
+			Action: func(c *cli.Context) error {
+				if c.NArg() == 0 {
+					return cli.NewExitError("Please give me a pipeline file.", 1)
+				}
+
+				p, err := loadPipeline(c.Args().First())
+				if err != nil {
+					return cli.NewExitError(err.Error(), 1)
+				}
+
+				so, err := saveOptionsFromContext(c)
+				if err != nil {
+					return cli.NewExitError(err.Error(), 1)
+				}
+
+				if err := p.run(c.Args().Get(1), c.Args().Get(2), so); err != nil {
+					return cli.NewExitError(err.Error(), 1)
+				}
+				return nil
+			},
+		},
+		{
+			Name:      "palette",
+			Usage:     "Print the dominant colors of an image as JSON",
+			ArgsUsage: "<image>",
+			Flags: []cli.Flag{
+				cli.IntFlag{
+					Name:  "colors, c",
+					Value: 5,
+					Usage: "Number of colors, from 1 to 256",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				if c.NArg() == 0 {
+					return cli.NewExitError("Please give me an image.", 1)
+				}
+				if n := c.Int("colors"); n < 1 || n > 256 {
+					return cli.NewExitError("The number of colors must be between 1 and 256.", 1)
+				}
+
+				img, err := leonard.LoadImage(c.Args().First())
+				if err != nil {
+					return cli.NewExitError(fmt.Sprintf("Decoding error: %s", err), 1)
+				}
+
+				if err := writePalette(os.Stdout, img, c.Int("colors")); err != nil {
+					return cli.NewExitError(err.Error(), 1)
+				}
+				return nil
+			},
+		},
+		{
+			Name:      "batch",
+			Usage:     "Apply transforms on many images",
+			ArgsUsage: "<image, directory or glob>...",
+			Flags: append([]cli.Flag{
+				cli.StringSliceFlag{
+					Name:  "transform, t",
+					Usage: "Transformation to apply; see the main command",
+				},
+				cli.StringFlag{
+					Name:  "pipeline, p",
+					Usage: "Pipeline file to use instead of --transform",
+				},
+				cli.StringFlag{
+					Name:  "output-dir, o",
+					Usage: "Directory where to write the images",
+				},
+				cli.StringFlag{
+					Name:  "name, n",
+					Value: "{name}{ext}",
+					Usage: "Template of the output filenames. {name} is the input filename without its extension and {ext} its extension",
+				},
+				cli.IntFlag{
+					Name:  "jobs, j",
+					Value: runtime.NumCPU(),
+					Usage: "Number of images processed in parallel",
+				},
+				cli.BoolFlag{
+					Name:  "skip-existing, s",
+					Usage: "Skip images whose output file already exists",
+				},
+			}, outputFlags...),
+			Action: func(c *cli.Context) error {
+				if c.NArg() == 0 {
+					return cli.NewExitError("Please give me some images.", 1)
+				}
+				if c.String("output-dir") == "" {
+					return cli.NewExitError("Please give me an output directory.", 1)
+				}
+				if c.Int("jobs") < 1 {
+					return cli.NewExitError("The number of jobs must be positive.", 1)
+				}
+
+				var p *pipeline
+				var err error
+
+				if c.String("pipeline") != "" {
+					p, err = loadPipeline(c.String("pipeline"))
+				} else {
+					p, err = pipelineFromSpecs(c.StringSlice("transform"))
+				}
+				if err != nil {
+					return cli.NewExitError(err.Error(), 1)
+				}
+				for i := range p.Steps {
+					if p.Steps[i].Save != "" {
+						return cli.NewExitError(
+							fmt.Sprintf("%s: intermediate saves are not supported in batch mode", &p.Steps[i]), 1)
+					}
+				}
+
+				so, err := saveOptionsFromContext(c)
+				if err != nil {
+					return cli.NewExitError(err.Error(), 1)
+				}
+
+				inputs, err := expandInputs(c.Args())
+				if err != nil {
+					return cli.NewExitError(err.Error(), 1)
+				}
+
+				b := &batch{
+					p:            p,
+					so:           so,
+					outputDir:    c.String("output-dir"),
+					template:     c.String("name"),
+					workers:      c.Int("jobs"),
+					skipExisting: c.Bool("skip-existing"),
+					progress:     os.Stderr,
+				}
+
+				if err := b.checkOutputs(inputs); err != nil {
+					return cli.NewExitError(err.Error(), 1)
+				}
+				if err := os.MkdirAll(b.outputDir, 0755); err != nil {
+					return cli.NewExitError(err.Error(), 1)
+				}
+
+				if failures := b.run(inputs); len(failures) > 0 {
+					return cli.NewExitError("", 1)
+				}
+				return nil
+			},
+		},
+	}
+
 	app.Run(os.Args)
 }
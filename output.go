@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+
+	"github.com/bfontaine/leonard/leonard"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// outputFlags are the flags that control how images are written
+var outputFlags = []cli.Flag{
+	cli.StringFlag{
+		Name:  "format, f",
+		Usage: "Output format (png, jpeg, gif, bmp, tiff, pbm, pgm, ppm, pnm). It's guessed from the output filename by default; PNG is used for stdout",
+	},
+	cli.IntFlag{
+		Name:  "quality, q",
+		Value: jpeg.DefaultQuality,
+		Usage: "JPEG quality, from 1 to 100",
+	},
+	cli.StringFlag{
+		Name:  "png-compression",
+		Value: "default",
+		Usage: "PNG compression level: default, none, fast or best",
+	},
+	cli.IntFlag{
+		Name:  "gif-colors",
+		Value: 256,
+		Usage: "Maximum number of colors in GIF palettes, from 1 to 256",
+	},
+	cli.StringFlag{
+		Name:  "gif-quantizer",
+		Value: "kmeans",
+		Usage: "How GIF palettes are built: kmeans, median-cut, or the fixed plan9 or websafe palettes",
+	},
+	cli.StringFlag{
+		Name:  "gif-drawer",
+		Value: "floyd-steinberg",
+		Usage: "How colors are mapped to the GIF palette: floyd-steinberg (dithering) or nearest",
+	},
+	cli.IntFlag{
+		Name:  "colors",
+		Usage: "Reduce the image to this number of colors, from 1 to 256, before saving it",
+	},
+	cli.BoolFlag{
+		Name:  "dither",
+		Usage: "Dither the image when reducing its number of colors with --colors",
+	},
+}
+
+var pngCompressionLevels = map[string]png.CompressionLevel{
+	"default": png.DefaultCompression,
+	"none":    png.NoCompression,
+	"fast":    png.BestSpeed,
+	"best":    png.BestCompression,
+}
+
+var gifQuantizers = map[string]draw.Quantizer{
+	"kmeans":     leonard.KMeansQuantizer{},
+	"median-cut": leonard.MedianCutQuantizer{},
+	"plan9":      leonard.PaletteQuantizer(palette.Plan9),
+	"websafe":    leonard.PaletteQuantizer(palette.WebSafe),
+}
+
+var gifDrawers = map[string]draw.Drawer{
+	"floyd-steinberg": draw.FloydSteinberg,
+	"nearest":         draw.Src,
+}
+
+// saveOptions describe how images are written
+type saveOptions struct {
+	// format of the output; guessed from the filename if empty
+	format string
+	encode *leonard.EncodeOptions
+	// if positive, images are quantized to this number of colors
+	colors int
+	dither bool
+}
+
+func saveOptionsFromContext(c *cli.Context) (saveOptions, error) {
+	var o saveOptions
+
+	if f := c.String("format"); f != "" {
+		format, err := leonard.LookupFormat(f)
+		if err != nil {
+			return o, err
+		}
+		if format.Encode == nil {
+			return o, fmt.Errorf("%s images can't be encoded", format.Name)
+		}
+		o.format = format.Name
+	}
+
+	quality := c.Int("quality")
+	if quality < 1 || quality > 100 {
+		return o, fmt.Errorf("The JPEG quality must be between 1 and 100")
+	}
+
+	compression, ok := pngCompressionLevels[c.String("png-compression")]
+	if !ok {
+		return o, fmt.Errorf("Unknown PNG compression level '%s'", c.String("png-compression"))
+	}
+
+	colors := c.Int("gif-colors")
+	if colors < 1 || colors > 256 {
+		return o, fmt.Errorf("The number of GIF colors must be between 1 and 256")
+	}
+
+	quantizer, ok := gifQuantizers[c.String("gif-quantizer")]
+	if !ok {
+		return o, fmt.Errorf("Unknown GIF quantizer '%s'", c.String("gif-quantizer"))
+	}
+
+	drawer, ok := gifDrawers[c.String("gif-drawer")]
+	if !ok {
+		return o, fmt.Errorf("Unknown GIF drawer '%s'", c.String("gif-drawer"))
+	}
+
+	if n := c.Int("colors"); n != 0 {
+		if n < 1 || n > 256 {
+			return o, fmt.Errorf("The number of colors must be between 1 and 256")
+		}
+		o.colors = n
+		o.dither = c.Bool("dither")
+	}
+
+	o.encode = &leonard.EncodeOptions{
+		JPEG: &jpeg.Options{Quality: quality},
+		GIF: &gif.Options{
+			NumColors: colors,
+			Quantizer: quantizer,
+			Drawer:    drawer,
+		},
+		PNGCompression: compression,
+	}
+
+	return o, nil
+}
+
+// save saves an image. The filename "-" means os.Stdout.
+func (o saveOptions) save(img image.Image, filename string) error {
+	if o.colors > 0 {
+		img = leonard.Quantize(img, o.colors, &leonard.QuantizeOptions{Dither: o.dither})
+	}
+	return leonard.SaveImageAs(img, filename, o.format, o.encode)
+}
+
+// intermediate returns the options used for intermediate images, whose format
+// is always guessed from their filename.
+func (o saveOptions) intermediate() saveOptions {
+	o.format = ""
+	return o
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+
+	"github.com/bfontaine/leonard/leonard"
+)
+
+// paletteColor is a dominant color as written in JSON
+type paletteColor struct {
+	// Color is "#rrggbb", or "#rrggbbaa" for transparent colors
+	Color    string  `json:"color"`
+	Fraction float64 `json:"fraction"`
+}
+
+// writePalette writes the n dominant colors of an image as JSON
+func writePalette(w io.Writer, img image.Image, n int) error {
+	colors := []paletteColor{}
+
+	for _, d := range leonard.DominantColors(img, n) {
+		c := d.Color
+		hex := fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+		if c.A != 0xff {
+			hex += fmt.Sprintf("%02x", c.A)
+		}
+		colors = append(colors, paletteColor{hex, d.Fraction})
+	}
+
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(colors)
+}
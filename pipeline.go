@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/bfontaine/leonard/leonard"
+	"gopkg.in/yaml.v2"
+)
+
+// inputNode is the name of the node holding the input image
+const inputNode = "input"
+
+// pipeline is a graph of transforms read from a YAML or JSON file:
+//
+//	input: scan.png
+//	output: masked.png
+//	steps:
+//	  - name: blurred
+//	    transform: blur
+//	    params:
+//	      sigma: 2.5
+//	    save: blurred.png
+//	  - name: mask
+//	    transform: binary
+//	    params:
+//	      threshold: otsu
+//	  - transform: mask
+//	    inputs: [input, mask]
+//
+// Each step takes its input from the previous one unless it lists the names
+// of earlier steps in its inputs; the input image is named "input". The output
+// image is the result of the last step.
+//
+// Relative paths, including the ones of image parameters, are resolved from
+// the directory of the pipeline file so that it gives the same results
+// regardless of where it's run from.
+type pipeline struct {
+	Input  string         `yaml:"input" json:"input"`
+	Output string         `yaml:"output" json:"output"`
+	Steps  []pipelineStep `yaml:"steps" json:"steps"`
+
+	dir   string
+	graph *leonard.Graph
+}
+
+// pipelineStep is a transform in a pipeline
+type pipelineStep struct {
+	// Name is an optional name used to refer to the step's output
+	Name      string                 `yaml:"name" json:"name"`
+	Transform string                 `yaml:"transform" json:"transform"`
+	Params    map[string]interface{} `yaml:"params" json:"params"`
+	Inputs    []string               `yaml:"inputs" json:"inputs"`
+	// Save is an optional path where to save the result of this step
+	Save string `yaml:"save" json:"save"`
+
+	// name of the step in the graph
+	node string
+}
+
+func (s *pipelineStep) String() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return s.Transform
+}
+
+// loadPipeline reads and validates a pipeline file
+func loadPipeline(filename string) (*pipeline, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var p pipeline
+
+	if strings.ToLower(filepath.Ext(filename)) == ".json" {
+		d := json.NewDecoder(bytes.NewReader(data))
+		d.DisallowUnknownFields()
+		err = d.Decode(&p)
+	} else {
+		err = yaml.UnmarshalStrict(data, &p)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+
+	p.dir = filepath.Dir(filename)
+
+	if err := p.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	return &p, nil
+}
+
+// formatParam converts a parameter value decoded from a pipeline file back to
+// its textual form, e.g. for transforms that take an integer given as a JSON
+// number.
+func formatParam(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		// fmt would use an exponent for large numbers, e.g. "1e+06"
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// validate checks the pipeline's steps against the registered transforms,
+// parses their parameters and builds the graph.
+func (p *pipeline) validate() error {
+	g := leonard.NewGraph()
+	if err := g.Input(inputNode); err != nil {
+		return err
+	}
+
+	previous := inputNode
+
+	for i := range p.Steps {
+		s := &p.Steps[i]
+
+		if s.Name == inputNode || strings.HasPrefix(s.Name, "#") {
+			return fmt.Errorf("step %d: reserved name '%s'", i+1, s.Name)
+		}
+		s.node = s.Name
+		if s.node == "" {
+			s.node = fmt.Sprintf("#%d", i+1)
+		}
+
+		if s.Transform == "" {
+			return fmt.Errorf("step %d: missing transform", i+1)
+		}
+
+		t, ok := lookupTransform(s.Transform)
+		if !ok {
+			return fmt.Errorf("step %d: unknown transform '%s'", i+1, s.Transform)
+		}
+
+		raw := make(map[string]string, len(s.Params))
+		for name, v := range s.Params {
+			raw[name] = formatParam(v)
+			if param, ok := t.param(name); ok && param.typ.name == imageType.name && raw[name] != "" {
+				raw[name] = p.path(raw[name])
+			}
+		}
+
+		a, err := t.parseArgs(raw)
+		if err != nil {
+			return fmt.Errorf("step %d: %s", i+1, err)
+		}
+
+		inputs := s.Inputs
+		if len(inputs) == 0 {
+			inputs = []string{previous}
+		}
+		if len(inputs) != t.arity() {
+			return fmt.Errorf("step %d: %s takes %d input(s), got %d",
+				i+1, t.name, t.arity(), len(inputs))
+		}
+
+		if err := g.Add(s.node, t.operation(a), inputs...); err != nil {
+			return fmt.Errorf("step %d: %s", i+1, err)
+		}
+
+		previous = s.node
+	}
+
+	p.graph = g
+	return nil
+}
+
+// path resolves a path relative to the pipeline file
+func (p *pipeline) path(filename string) string {
+	if filename == "-" || filepath.IsAbs(filename) {
+		return filename
+	}
+	return filepath.Join(p.dir, filename)
+}
+
+// run executes the pipeline. input and output override the paths given in the
+// file if they're not empty.
+func (p *pipeline) run(input, output string, so saveOptions) error {
+	if input == "" {
+		if p.Input == "" {
+			return fmt.Errorf("no input image")
+		}
+		input = p.path(p.Input)
+	}
+	if output == "" {
+		if p.Output == "" {
+			return fmt.Errorf("no output file")
+		}
+		output = p.path(p.Output)
+	}
+
+	img, err := leonard.LoadImage(input)
+	if err != nil {
+		return fmt.Errorf("Decoding error: %s", err)
+	}
+
+	img, err = p.apply(img, so.intermediate())
+	if err != nil {
+		return err
+	}
+
+	if err := so.save(img, output); err != nil {
+		return fmt.Errorf("Write error: %s", err)
+	}
+	return nil
+}
+
+// apply runs the pipeline's graph on an image, saving intermediate results
+// when asked to, and returns the result of the last step.
+func (p *pipeline) apply(img image.Image, so saveOptions) (image.Image, error) {
+	if len(p.Steps) == 0 {
+		return img, nil
+	}
+
+	images, err := p.graph.Run(map[string]image.Image{inputNode: img})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range p.Steps {
+		s := &p.Steps[i]
+
+		if s.Save != "" {
+			if err := so.save(images[s.node], p.path(s.Save)); err != nil {
+				return nil, fmt.Errorf("%s: write error: %s", s, err)
+			}
+		}
+	}
+
+	return images[p.Steps[len(p.Steps)-1].node], nil
+}
+
+// parseStep parses a command-line step specification of the form
+//
+//	[label=]transform[:param1=value1,param2=value2][@input1,input2]
+//
+// The label names the step's output so that later steps can refer to it in
+// their inputs.
+func parseStep(spec string) (pipelineStep, error) {
+	var s pipelineStep
+
+	if i := strings.LastIndexByte(spec, '@'); i >= 0 {
+		s.Inputs = strings.Split(spec[i+1:], ",")
+		spec = spec[:i]
+	}
+
+	colon := strings.IndexByte(spec, ':')
+	if eq := strings.IndexByte(spec, '='); eq >= 0 && (colon < 0 || eq < colon) {
+		s.Name = spec[:eq]
+		spec = spec[eq+1:]
+		colon = strings.IndexByte(spec, ':')
+	}
+
+	s.Transform = spec
+	if colon >= 0 {
+		s.Transform = spec[:colon]
+
+		s.Params = make(map[string]interface{})
+		for _, kv := range strings.Split(spec[colon+1:], ",") {
+			i := strings.IndexByte(kv, '=')
+			if i <= 0 {
+				return s, fmt.Errorf("%s: expected name=value, got '%s'", s.Transform, kv)
+			}
+			name := kv[:i]
+			if _, ok := s.Params[name]; ok {
+				return s, fmt.Errorf("%s: parameter '%s' given twice", s.Transform, name)
+			}
+			s.Params[name] = kv[i+1:]
+		}
+	}
+
+	return s, nil
+}
+
+// pipelineFromSpecs creates a pipeline from command-line step specifications;
+// see parseStep.
+func pipelineFromSpecs(specs []string) (*pipeline, error) {
+	p := &pipeline{}
+
+	for _, spec := range specs {
+		s, err := parseStep(spec)
+		if err != nil {
+			return nil, err
+		}
+		p.Steps = append(p.Steps, s)
+	}
+
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bfontaine/leonard/leonard"
+)
+
+func TestFormatParam(t *testing.T) {
+	for _, tt := range []struct {
+		v    interface{}
+		want string
+	}{
+		{"otsu", "otsu"},
+		{1000000.0, "1000000"},
+		{2.5, "2.5"},
+		{1000000, "1000000"},
+		{true, "true"},
+	} {
+		if got := formatParam(tt.v); got != tt.want {
+			t.Errorf("%#v: got '%s', want '%s'", tt.v, got, tt.want)
+		}
+	}
+}
+
+// writePipeline writes a pipeline file in a temporary directory
+func writePipeline(t *testing.T, name, content string) string {
+	dir, err := ioutil.TempDir("", "leonard")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	filename := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestLoadPipelineJSONNumbers(t *testing.T) {
+	filename := writePipeline(t, "p.json", `{
+		"steps": [{"transform": "resize", "params": {"w": 1000000}}]
+	}`)
+
+	p, err := loadPipeline(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Steps) != 1 {
+		t.Fatalf("got %d steps, want 1", len(p.Steps))
+	}
+}
+
+func TestLoadPipelineErrors(t *testing.T) {
+	for name, content := range map[string]string{
+		"unknown.yml":  "steps:\n  - transform: nope\n",
+		"reserved.yml": "steps:\n  - name: input\n    transform: blur\n",
+		"param.yml":    "steps:\n  - transform: blur\n    params:\n      radius: 2\n",
+		"inputs.yml":   "steps:\n  - transform: blur\n    inputs: [missing]\n",
+		"field.json":   `{"steps": [{"transform": "blur", "sigma": 2}]}`,
+	} {
+		if _, err := loadPipeline(writePipeline(t, name, content)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestPipelineFromSpecsInputs(t *testing.T) {
+	p, err := pipelineFromSpecs([]string{"sum=add@input,input", "subtract@sum,input"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img := image.NewGray(image.Rect(0, 0, 2, 2))
+	for i := range img.Pix {
+		img.Pix[i] = 50
+	}
+
+	out, err := p.apply(img, saveOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// (50 + 50) - 50; the inputs would give 0 if they were swapped
+	if v := color.GrayModel.Convert(out.At(1, 1)).(color.Gray).Y; v != 50 {
+		t.Errorf("got %d, want 50", v)
+	}
+}
+
+func TestPipelineFromSpecsInputsErrors(t *testing.T) {
+	for _, specs := range [][]string{
+		// add takes 2 inputs and gets the previous step
+		{"add"},
+		{"add@input"},
+		{"blur@input,input"},
+		{"blur@missing"},
+		// steps can only refer to earlier ones
+		{"blur@later", "later=gray"},
+		{"a=gray", "a=blur"},
+	} {
+		if _, err := pipelineFromSpecs(specs); err == nil {
+			t.Errorf("%v: expected an error", specs)
+		}
+	}
+}
+
+func TestLoadPipelineImageParams(t *testing.T) {
+	filename := writePipeline(t, "p.yml", "steps:\n  - transform: match-template\n    params:\n      template: logo.png\n")
+
+	// the template is next to the pipeline file, not in the current directory
+	logo := filepath.Join(filepath.Dir(filename), "logo.png")
+	if err := leonard.SaveImage(image.NewGray(image.Rect(0, 0, 2, 2)), logo); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := loadPipeline(filename); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,815 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/bfontaine/leonard/leonard"
+)
+
+// paramType describes how a parameter value is parsed from its string
+// representation.
+type paramType struct {
+	name  string
+	parse func(string) (interface{}, error)
+}
+
+var (
+	intType = paramType{"int", func(s string) (interface{}, error) {
+		return strconv.Atoi(s)
+	}}
+
+	floatType = paramType{"float", func(s string) (interface{}, error) {
+		return strconv.ParseFloat(s, 64)
+	}}
+
+	stringType = paramType{"string", func(s string) (interface{}, error) {
+		return s, nil
+	}}
+
+	// imageType is the path of an image, loaded when the parameter is parsed.
+	// An empty path gives a nil image.
+	imageType = paramType{"image", func(s string) (interface{}, error) {
+		if s == "" {
+			return nil, nil
+		}
+		return leonard.LoadImage(s)
+	}}
+
+	// optionalFloatType is a float that can be left empty, e.g. when its
+	// default depends on other parameters. An empty value gives nil.
+	optionalFloatType = paramType{"float", func(s string) (interface{}, error) {
+		if s == "" {
+			return nil, nil
+		}
+		return strconv.ParseFloat(s, 64)
+	}}
+
+	// thresholdType is either a float between 0 and 1 or "otsu"
+	thresholdType = paramType{"threshold", func(s string) (interface{}, error) {
+		if s == "otsu" {
+			return s, nil
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return nil, fmt.Errorf("expected a number or 'otsu'")
+		}
+		if f < 0 || f > 1 {
+			return nil, fmt.Errorf("must be between 0 and 1")
+		}
+		return f, nil
+	}}
+)
+
+// param is a parameter of a transform
+type param struct {
+	name        string
+	typ         paramType
+	def         string
+	description string
+	// optional additional validation
+	check func(interface{}) error
+}
+
+func (p param) parse(s string) (interface{}, error) {
+	v, err := p.typ.parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value '%s' for %s: %s", s, p.name, err)
+	}
+	if p.check != nil {
+		if err := p.check(v); err != nil {
+			return nil, fmt.Errorf("invalid value '%s' for %s: %s", s, p.name, err)
+		}
+	}
+	return v, nil
+}
+
+func positive(v interface{}) error {
+	switch n := v.(type) {
+	case int:
+		if n > 0 {
+			return nil
+		}
+	case float64:
+		if n > 0 {
+			return nil
+		}
+	}
+	return fmt.Errorf("must be positive")
+}
+
+func notNegative(v interface{}) error {
+	switch n := v.(type) {
+	case int:
+		if n >= 0 {
+			return nil
+		}
+	case float64:
+		if n >= 0 {
+			return nil
+		}
+	}
+	return fmt.Errorf("must not be negative")
+}
+
+func between(min, max float64) func(interface{}) error {
+	return func(v interface{}) error {
+		var f float64
+		switch n := v.(type) {
+		case int:
+			f = float64(n)
+		case float64:
+			f = n
+		}
+		if f < min || f > max {
+			return fmt.Errorf("must be between %v and %v", min, max)
+		}
+		return nil
+	}
+}
+
+func oneOf(choices ...string) func(interface{}) error {
+	return func(v interface{}) error {
+		for _, c := range choices {
+			if v == c {
+				return nil
+			}
+		}
+		return fmt.Errorf("must be one of %s", strings.Join(choices, ", "))
+	}
+}
+
+// args are the parsed parameters passed to a transform
+type args map[string]interface{}
+
+func (a args) int(name string) int           { return a[name].(int) }
+func (a args) float(name string) float64     { return a[name].(float64) }
+func (a args) string(name string) string     { return a[name].(string) }
+func (a args) value(name string) interface{} { return a[name] }
+func (a args) image(name string) image.Image {
+	img, _ := a[name].(image.Image)
+	return img
+}
+
+// transform is an image transformation available from the command-line
+type transform struct {
+	name        string
+	description string
+	params      []param
+	// optional validation of the whole set of parameters
+	check func(args) error
+	apply func(image.Image, args) image.Image
+
+	// transforms that take more than one image set inputs and applyN instead
+	// of apply. applyN returns an error if the images don't fit together.
+	inputs int
+	applyN func([]image.Image, args) (image.Image, error)
+}
+
+// arity returns the number of images the transform takes
+func (t *transform) arity() int {
+	if t.inputs == 0 {
+		return 1
+	}
+	return t.inputs
+}
+
+// operation returns a graph operation that applies the transform with the
+// given arguments.
+func (t *transform) operation(a args) leonard.Operation {
+	if t.applyN != nil {
+		n := t.inputs
+		return func(inputs ...image.Image) (image.Image, error) {
+			if len(inputs) != n {
+				return nil, fmt.Errorf("expected %d inputs, got %d", n, len(inputs))
+			}
+			return t.applyN(inputs, a)
+		}
+	}
+	return leonard.UnaryOperation(func(img image.Image) image.Image {
+		return t.apply(img, a)
+	})
+}
+
+func (t *transform) param(name string) (param, bool) {
+	for _, p := range t.params {
+		if p.name == name {
+			return p, true
+		}
+	}
+	return param{}, false
+}
+
+// parseArgs parses raw "name=value" parameters and fill the missing ones with
+// their default values.
+func (t *transform) parseArgs(raw map[string]string) (args, error) {
+	a := make(args)
+
+	for name, s := range raw {
+		p, ok := t.param(name)
+		if !ok {
+			return nil, fmt.Errorf("%s: unknown parameter '%s'", t.name, name)
+		}
+		v, err := p.parse(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", t.name, err)
+		}
+		a[name] = v
+	}
+
+	for _, p := range t.params {
+		if _, ok := a[p.name]; ok {
+			continue
+		}
+		v, err := p.parse(p.def)
+		if err != nil {
+			// this is a bug in the transform definition
+			panic(fmt.Sprintf("%s: bad default: %s", t.name, err))
+		}
+		a[p.name] = v
+	}
+
+	if t.check != nil {
+		if err := t.check(a); err != nil {
+			return nil, fmt.Errorf("%s: %s", t.name, err)
+		}
+	}
+
+	return a, nil
+}
+
+// threshold converts a parsed threshold parameter in a value usable by
+// leonard.NewBinaryImage.
+func threshold(img image.Image, v interface{}) int {
+	if v == "otsu" {
+		return leonard.OtsuThreshold(img)
+	}
+	return int(math.Ceil(0xffff * v.(float64)))
+}
+
+var gradientOperators = map[string]leonard.GradientOperator{
+	"central": leonard.CentralDifference,
+	"sobel":   leonard.Sobel,
+	"sobel5":  leonard.Sobel5,
+	"scharr":  leonard.Scharr,
+	"prewitt": leonard.Prewitt,
+	"roberts": leonard.Roberts,
+}
+
+var gradientNormalizations = map[string]leonard.GradientNormalization{
+	"max":        leonard.MaxNormalization,
+	"none":       leonard.NoNormalization,
+	"fixed":      leonard.FixedNormalization,
+	"percentile": leonard.PercentileNormalization,
+}
+
+// gradientParams are the parameters of the transforms that compute gradients
+var gradientParams = []param{
+	{"operator", stringType, "central",
+		"gradient operator: central, sobel, sobel5, scharr, prewitt or roberts",
+		oneOf("central", "sobel", "sobel5", "scharr", "prewitt", "roberts")},
+	{"normalize", stringType, "max",
+		"how gradients are mapped to 0-1: max (of the image), none, fixed (see range) or percentile",
+		oneOf("max", "none", "fixed", "percentile")},
+	{"range", floatType, "1.0", "gradient mapped to 1 with normalize=fixed", positive},
+	{"percentile", floatType, "0.99", "percentile mapped to 1 with normalize=percentile", between(0, 1)},
+}
+
+// gradientOptions returns the gradient options given by the gradientParams of
+// a transform.
+func gradientOptions(a args) *leonard.GradientOptions {
+	return &leonard.GradientOptions{
+		Operator:      gradientOperators[a.string("operator")],
+		Normalization: gradientNormalizations[a.string("normalize")],
+		Range:         a.float("range"),
+		Percentile:    a.float("percentile"),
+	}
+}
+
+// gradients calls one of the leonard.*GradientsWithOptions functions with the
+// options given by the gradientParams of a transform.
+func gradients(fn func(image.Image, *leonard.GradientOptions) (image.Image, error), img image.Image, a args) image.Image {
+	g, err := fn(img, gradientOptions(a))
+	if err != nil {
+		// the parameters are validated when they're parsed, so this is a bug
+		// in gradientParams
+		panic(err)
+	}
+	return g
+}
+
+// gradientField computes the gradients of an image with the options given by
+// the gradientParams of a transform.
+func gradientField(img image.Image, a args) *leonard.GradientField {
+	g, err := leonard.NewGradientFieldWithOptions(img, gradientOptions(a))
+	if err != nil {
+		// see gradients
+		panic(err)
+	}
+	return g
+}
+
+var ditherAlgorithms = map[string]leonard.DitherAlgorithm{
+	"floyd-steinberg":     leonard.FloydSteinberg,
+	"atkinson":            leonard.Atkinson,
+	"jarvis-judice-ninke": leonard.JarvisJudiceNinke,
+	"stucki":              leonard.Stucki,
+	"bayer2":              leonard.Bayer2,
+	"bayer4":              leonard.Bayer4,
+	"bayer8":              leonard.Bayer8,
+}
+
+var cornerDetectors = map[string]leonard.CornerDetector{
+	"harris":     leonard.Harris,
+	"shi-tomasi": leonard.ShiTomasi,
+	"fast":       leonard.FAST,
+}
+
+var templateMatchMethods = map[string]leonard.TemplateMatchMethod{
+	"ssd":  leonard.SSD,
+	"ncc":  leonard.NCC,
+	"zncc": leonard.ZNCC,
+}
+
+// templateMatchThresholds are the default threshold of each template matching
+// method and the range of its scores. Lower is better with SSD.
+var templateMatchThresholds = map[string]struct{ def, min, max float64 }{
+	"ssd":  {0.1, 0, 1},
+	"ncc":  {0.8, 0, 1},
+	"zncc": {0.8, -1, 1},
+}
+
+var thinningAlgorithms = map[string]leonard.ThinningAlgorithm{
+	"zhang-suen":  leonard.ZhangSuen,
+	"zhang-wang":  leonard.ZhangWang,
+	"guo-hall":    leonard.GuoHall,
+	"medial-axis": leonard.MedialAxis,
+}
+
+var distanceMetrics = map[string]leonard.DistanceMetric{
+	"euclidean":  leonard.EuclideanDistance,
+	"chamfer":    leonard.ChamferDistance,
+	"city-block": leonard.CityBlockDistance,
+}
+
+// skeletonParams are the parameters of the transforms that thin shapes
+var skeletonParams = []param{
+	{"thinning", stringType, "zhang-suen",
+		"thinning algorithm: zhang-suen, zhang-wang, guo-hall or medial-axis",
+		oneOf("zhang-suen", "zhang-wang", "guo-hall", "medial-axis")},
+	{"prune", intType, "0", "remove the spurs shorter than this number of pixels", notNegative},
+}
+
+// skeleton thins a binary image as set by the skeletonParams of a transform
+func skeleton(b *leonard.BinaryImage, a args) *leonard.BinaryImage {
+	b.Thin(thinningAlgorithms[a.string("thinning")])
+	if n := a.int("prune"); n > 0 {
+		b.Prune(n)
+	}
+	return b
+}
+
+var transforms = []*transform{
+	{
+		name:        "gray",
+		description: "Convert the image to grayscale",
+		apply: func(i image.Image, _ args) image.Image {
+			return leonard.Grayscale(i)
+		},
+	},
+	{
+		name:        "depth",
+		description: "Change the number of bits per channel. Convert 8-bit images to 16 bits to keep the precision of the next transforms",
+		params: []param{
+			{"bits", intType, "16", "8 or 16", func(v interface{}) error {
+				if v != 8 && v != 16 {
+					return fmt.Errorf("must be 8 or 16")
+				}
+				return nil
+			}},
+		},
+		apply: func(i image.Image, a args) image.Image {
+			if a.int("bits") == 8 {
+				return leonard.To8Bit(i)
+			}
+			return leonard.To16Bit(i)
+		},
+	},
+	{
+		name:        "binary",
+		description: "Convert the image to black & white",
+		params: []param{
+			{"threshold", thresholdType, "0.6", "luminance threshold between 0 and 1, or 'otsu'", nil},
+		},
+		apply: func(i image.Image, a args) image.Image {
+			return leonard.NewBinaryImage(i, threshold(i, a.value("threshold")))
+		},
+	},
+	{
+		name:        "dither",
+		description: "Reduce the number of colors of the image while keeping its tones, by default to black & white",
+		params: []param{
+			{"algorithm", stringType, "floyd-steinberg",
+				"floyd-steinberg, atkinson, jarvis-judice-ninke, stucki, bayer2, bayer4 or bayer8",
+				oneOf("floyd-steinberg", "atkinson", "jarvis-judice-ninke", "stucki", "bayer2", "bayer4", "bayer8")},
+			{"colors", intType, "0", "number of colors of a palette built from the image; 0 for black & white", between(0, 256)},
+		},
+		apply: func(i image.Image, a args) image.Image {
+			alg := ditherAlgorithms[a.string("algorithm")]
+			n := a.int("colors")
+			if n == 0 {
+				return leonard.Dither(i, alg)
+			}
+			palette := leonard.KMeansQuantizer{}.Quantize(make(color.Palette, 0, n), i)
+			return leonard.DitherPaletted(i, palette, alg)
+		},
+	},
+	{
+		name:        "vgradients",
+		description: "Compute the magnitude of the vertical gradients",
+		params:      gradientParams,
+		apply: func(i image.Image, a args) image.Image {
+			return gradients(leonard.VerticalGradientsWithOptions, i, a)
+		},
+	},
+	{
+		name:        "hgradients",
+		description: "Compute the magnitude of the horizontal gradients",
+		params:      gradientParams,
+		apply: func(i image.Image, a args) image.Image {
+			return gradients(leonard.HorizontalGradientsWithOptions, i, a)
+		},
+	},
+	{
+		name:        "gradients",
+		description: "Compute the magnitude of the gradients",
+		params:      gradientParams,
+		apply: func(i image.Image, a args) image.Image {
+			return gradients(leonard.GradientsWithOptions, i, a)
+		},
+	},
+	{
+		name:        "gradient-direction",
+		description: "Show the direction of the gradients as hues and their magnitude as values",
+		params:      gradientParams,
+		apply: func(i image.Image, a args) image.Image {
+			return gradientField(i, a).DirectionImage()
+		},
+	},
+	{
+		name:        "laplacian",
+		description: "Compute the Laplacian of the image; 0 is mapped to gray",
+		apply: func(i image.Image, _ args) image.Image {
+			return leonard.Laplacian(i).Image(leonard.SignedPolicy)
+		},
+	},
+	{
+		name:        "downscale",
+		description: "Halve the width and height of the image",
+		apply: func(i image.Image, _ args) image.Image {
+			return leonard.Downscale(i)
+		},
+	},
+	{
+		name:        "resize",
+		description: "Resize the image. If only one dimension is given the aspect ratio is kept",
+		params: []param{
+			{"w", intType, "0", "width in pixels", notNegative},
+			{"h", intType, "0", "height in pixels", notNegative},
+		},
+		check: func(a args) error {
+			if a.int("w") == 0 && a.int("h") == 0 {
+				return fmt.Errorf("w or h must be given")
+			}
+			return nil
+		},
+		apply: func(i image.Image, a args) image.Image {
+			return leonard.Resize(i, a.int("w"), a.int("h"))
+		},
+	},
+	{
+		name:        "blur",
+		description: "Apply a gaussian filter",
+		params: []param{
+			{"sigma", floatType, "1.4", "standard deviation of the gaussian", positive},
+		},
+		apply: func(i image.Image, a args) image.Image {
+			return leonard.GaussianFilter(i, a.float("sigma"))
+		},
+	},
+	{
+		name:        "sharpen",
+		description: "Sharpen the image with an unsharp mask",
+		params: []param{
+			{"sigma", floatType, "1.4", "standard deviation of the gaussian", positive},
+			{"amount", floatType, "1.0", "strength of the sharpening", notNegative},
+			{"threshold", intType, "0", "minimal difference (0-65535) for a pixel to be sharpened", between(0, 0xffff)},
+		},
+		apply: func(i image.Image, a args) image.Image {
+			return leonard.UnsharpMask(i, a.float("sigma"), a.float("amount"), a.int("threshold"))
+		},
+	},
+	{
+		name:        "edges",
+		description: "Detect the edges of the image",
+		params: append(append([]param{
+			{"sigma", floatType, "5.0", "standard deviation of the gaussian applied before", positive},
+			{"threshold", thresholdType, "0.16", "gradient threshold between 0 and 1, or 'otsu'", nil},
+		}, gradientParams...), skeletonParams...),
+		apply: func(i image.Image, a args) image.Image {
+			g := gradients(leonard.GradientsWithOptions, leonard.GaussianFilter(i, a.float("sigma")), a)
+			b := leonard.NewBinaryImage(g, threshold(g, a.value("threshold")))
+
+			skeleton(b, a)
+
+			// acc := b.HoughTransform()
+			// b.DrawLines(acc)
+
+			return b
+		},
+	},
+	{
+		name:        "skeleton",
+		description: "Thin the white shapes of the image down to their skeleton",
+		params: append([]param{
+			{"threshold", thresholdType, "0.5", "luminance threshold between 0 and 1, or 'otsu'", nil},
+		}, skeletonParams...),
+		apply: func(i image.Image, a args) image.Image {
+			return skeleton(leonard.NewBinaryImage(i, threshold(i, a.value("threshold"))), a)
+		},
+	},
+	{
+		name:        "distance",
+		description: "Compute the distance from each pixel to the closest white one",
+		params: []param{
+			{"threshold", thresholdType, "0.5", "luminance threshold between 0 and 1, or 'otsu'", nil},
+			{"metric", stringType, "euclidean", "euclidean, chamfer or city-block",
+				oneOf("euclidean", "chamfer", "city-block")},
+			{"max", floatType, "0", "distance in pixels mapped to white; 0 for the highest one", notNegative},
+		},
+		apply: func(i image.Image, a args) image.Image {
+			b := leonard.NewBinaryImage(i, threshold(i, a.value("threshold")))
+			d := b.DistanceTransform(distanceMetrics[a.string("metric")])
+
+			if _, max := d.MinMax(); math.IsInf(float64(max), 1) {
+				// no white pixel
+				return b
+			}
+
+			if max := a.float("max"); max > 0 {
+				for j, v := range d.Pix {
+					d.Pix[j] = v / float32(max)
+				}
+				return d.Image(leonard.ClampPolicy)
+			}
+			return d.Image(leonard.AbsPolicy)
+		},
+	},
+	{
+		name:        "watershed",
+		description: "Segment the image with a watershed on its gradients and draw the regions over it",
+		params: append([]param{
+			{"sigma", floatType, "2.0", "standard deviation of the gaussian applied before computing the gradients", positive},
+			{"depth", floatType, "0.05", "minimal depth of the basins, between 0 and 1", between(0, 1)},
+			{"opacity", floatType, "0.5", "opacity of the regions' colors", between(0, 1)},
+		}, gradientParams...),
+		apply: func(i image.Image, a args) image.Image {
+			g := gradients(leonard.GradientsWithOptions, leonard.GaussianFilter(i, a.float("sigma")), a)
+			return leonard.AutoWatershed(g, a.float("depth")).Overlay(i, a.float("opacity"))
+		},
+	},
+	{
+		name:        "watershed-markers",
+		description: "Segment the first image with a watershed on its gradients from the white areas of the second one, and draw the regions over it",
+		inputs:      2,
+		params: append([]param{
+			{"sigma", floatType, "2.0", "standard deviation of the gaussian applied before computing the gradients", positive},
+			{"threshold", thresholdType, "0.5", "luminance threshold of the markers between 0 and 1, or 'otsu'", nil},
+			{"opacity", floatType, "0.5", "opacity of the regions' colors", between(0, 1)},
+		}, gradientParams...),
+		applyN: func(i []image.Image, a args) (image.Image, error) {
+			img, m := i[0], i[1]
+			bounds := img.Bounds()
+			if size := m.Bounds().Size(); size != bounds.Size() {
+				return nil, fmt.Errorf("the markers must have the same size as the image (%dx%d), got %dx%d",
+					bounds.Dx(), bounds.Dy(), size.X, size.Y)
+			}
+
+			binary := leonard.NewBinaryImage(m, threshold(m, a.value("threshold")))
+			components := binary.ConnectedComponents(leonard.Connectivity8)
+
+			// the images may not have the same origin
+			markers := leonard.NewLabelImage(bounds)
+			d := m.Bounds().Min.Sub(bounds.Min)
+			for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+				for x := bounds.Min.X; x < bounds.Max.X; x++ {
+					markers.SetLabel(x, y, components.Label(x+d.X, y+d.Y))
+				}
+			}
+
+			g := gradients(leonard.GradientsWithOptions, leonard.GaussianFilter(img, a.float("sigma")), a)
+			labels, err := leonard.Watershed(g, markers)
+			if err != nil {
+				return nil, err
+			}
+			return labels.Overlay(img, a.float("opacity")), nil
+		},
+	},
+	{
+		name:        "superpixels",
+		description: "Segment the image in superpixels with SLIC",
+		params: []param{
+			{"segments", intType, "200", "approximate number of superpixels", positive},
+			{"compactness", floatType, "10", "higher values give more regular superpixels", positive},
+			{"render", stringType, "boundaries", "draw the boundaries, fill the superpixels with their mean color or with random colors",
+				oneOf("boundaries", "mean", "labels")},
+		},
+		apply: func(i image.Image, a args) image.Image {
+			labels := leonard.SLIC(i, a.int("segments"), a.float("compactness"))
+			switch a.string("render") {
+			case "mean":
+				return labels.MeanColors(i)
+			case "labels":
+				return labels
+			default:
+				return labels.Boundaries(i, color.RGBA{0xff, 0, 0, 0xff})
+			}
+		},
+	},
+	{
+		name:        "flood-fill",
+		description: "Select the area of similar colors around a pixel, like a magic wand",
+		params: []param{
+			{"x", intType, "0", "x coordinate of the seed pixel", notNegative},
+			{"y", intType, "0", "y coordinate of the seed pixel", notNegative},
+			{"tolerance", floatType, "0.1", "maximal difference of each channel, between 0 and 1", between(0, 1)},
+			{"connectivity", intType, "4", "4 or 8 neighbours", func(v interface{}) error {
+				if v != 4 && v != 8 {
+					return fmt.Errorf("must be 4 or 8")
+				}
+				return nil
+			}},
+			{"compare", stringType, "seed", "compare the colors to the seed's or to the region's mean",
+				oneOf("seed", "mean")},
+		},
+		apply: func(i image.Image, a args) image.Image {
+			seed := image.Pt(a.int("x"), a.int("y")).Add(i.Bounds().Min)
+			connectivity := leonard.Connectivity(a.int("connectivity"))
+
+			if a.string("compare") == "mean" {
+				return leonard.GrowRegion(i, []image.Point{seed}, connectivity,
+					leonard.MeanSimilarity(a.float("tolerance")))
+			}
+			return leonard.FloodFill(i, seed, a.float("tolerance"), connectivity)
+		},
+	},
+	{
+		name:        "corners",
+		description: "Detect the corners of the image and draw them over it",
+		params: []param{
+			{"detector", stringType, "harris", "harris, shi-tomasi or fast", oneOf("harris", "shi-tomasi", "fast")},
+			{"operator", stringType, "sobel", "gradient operator of harris and shi-tomasi; see gradients",
+				oneOf("central", "sobel", "sobel5", "scharr", "prewitt", "roberts")},
+			{"sigma", floatType, "1.0", "size of the window of harris and shi-tomasi", positive},
+			{"k", floatType, "0.04", "sensitivity of harris", positive},
+			{"contrast", floatType, "0.1", "luminance difference between 0 and 1 used by fast", between(0, 1)},
+			{"arc", intType, "9", "number of contiguous pixels used by fast, between 9 and 16", between(9, 16)},
+			{"quality", floatType, "0.01", "drop the corners weaker than this fraction of the strongest one", between(0, 1)},
+			{"radius", intType, "3", "minimal distance between two corners", positive},
+			{"max", intType, "0", "maximal number of corners; 0 for no limit", notNegative},
+		},
+		apply: func(i image.Image, a args) image.Image {
+			corners, err := leonard.Corners(i, &leonard.CornerOptions{
+				Detector: cornerDetectors[a.string("detector")],
+				Operator: gradientOperators[a.string("operator")],
+				Sigma:    a.float("sigma"),
+				K:        a.float("k"),
+				Contrast: a.float("contrast"),
+				Arc:      a.int("arc"),
+				Quality:  a.float("quality"),
+				Radius:   a.int("radius"),
+				Max:      a.int("max"),
+			})
+			if err != nil {
+				// the parameters are validated when they're parsed, so this
+				// is a bug in them
+				panic(err)
+			}
+			return leonard.DrawKeypoints(i, corners, color.RGBA{0xff, 0, 0, 0xff})
+		},
+	},
+	{
+		name:        "match-template",
+		description: "Find a template, e.g. a logo, in the image and draw the matches over it",
+		params: []param{
+			{"template", imageType, "", "path of the template image, relative to the pipeline file if any", nil},
+			{"method", stringType, "zncc", "ssd, ncc or zncc (zero-mean ncc)", oneOf("ssd", "ncc", "zncc")},
+			{"threshold", optionalFloatType, "", "minimal score of the matches, or maximal with ssd; 0.8 by default, 0.1 with ssd", nil},
+			{"max", intType, "0", "maximal number of matches; 0 for no limit", notNegative},
+		},
+		check: func(a args) error {
+			if a.image("template") == nil {
+				return fmt.Errorf("template must be given")
+			}
+			if v, ok := a.value("threshold").(float64); ok {
+				method := a.string("method")
+				r := templateMatchThresholds[method]
+				if v < r.min || v > r.max {
+					return fmt.Errorf("threshold must be between %v and %v with %s", r.min, r.max, method)
+				}
+			}
+			return nil
+		},
+		apply: func(i image.Image, a args) image.Image {
+			tmpl := a.image("template")
+			if tb, ib := tmpl.Bounds(), i.Bounds(); tb.Dx() > ib.Dx() || tb.Dy() > ib.Dy() {
+				// nothing to find
+				return i
+			}
+
+			method := a.string("method")
+			m := leonard.MatchTemplate(i, tmpl, templateMatchMethods[method])
+
+			threshold, ok := a.value("threshold").(float64)
+			if !ok {
+				threshold = templateMatchThresholds[method].def
+			}
+
+			var rects []image.Rectangle
+			for _, match := range m.Best(threshold, a.int("max")) {
+				rects = append(rects, m.Rect(match))
+			}
+			return leonard.DrawRectangles(i, rects, color.RGBA{0xff, 0, 0, 0xff})
+		},
+	},
+	{
+		name:        "add",
+		description: "Add two images",
+		inputs:      2,
+		applyN: func(i []image.Image, _ args) (image.Image, error) {
+			return leonard.Add(i[0], i[1]), nil
+		},
+	},
+	{
+		name:        "subtract",
+		description: "Subtract the second image from the first one",
+		inputs:      2,
+		applyN: func(i []image.Image, _ args) (image.Image, error) {
+			return leonard.Subtract(i[0], i[1]), nil
+		},
+	},
+	{
+		name:        "multiply",
+		description: "Multiply two images",
+		inputs:      2,
+		applyN: func(i []image.Image, _ args) (image.Image, error) {
+			return leonard.Multiply(i[0], i[1]), nil
+		},
+	},
+	{
+		name:        "blend",
+		description: "Blend two images",
+		inputs:      2,
+		params: []param{
+			{"alpha", floatType, "0.5", "weight of the second image", between(0, 1)},
+		},
+		applyN: func(i []image.Image, a args) (image.Image, error) {
+			return leonard.Blend(i[0], i[1], a.float("alpha")), nil
+		},
+	},
+	{
+		name:        "mask",
+		description: "Mask the first image with the luminance of the second one",
+		inputs:      2,
+		applyN: func(i []image.Image, _ args) (image.Image, error) {
+			return leonard.Mask(i[0], i[1]), nil
+		},
+	},
+}
+
+func lookupTransform(name string) (*transform, bool) {
+	for _, t := range transforms {
+		if t.name == name {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// printTransforms prints the available transforms with their parameters
+func printTransforms() {
+	for _, t := range transforms {
+		if n := t.arity(); n > 1 {
+			fmt.Printf("%s (%d inputs)\n    %s\n", t.name, n, t.description)
+		} else {
+			fmt.Printf("%s\n    %s\n", t.name, t.description)
+		}
+		for _, p := range t.params {
+			fmt.Printf("    %s=%s (%s): %s\n", p.name, p.def, p.typ.name, p.description)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseArgsDefaults(t *testing.T) {
+	tr, ok := lookupTransform("resize")
+	if !ok {
+		t.Fatal("resize isn't registered")
+	}
+
+	a, err := tr.parseArgs(map[string]string{"w": "100"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.int("w") != 100 || a.int("h") != 0 {
+		t.Errorf("got w=%d h=%d, want w=100 h=0", a.int("w"), a.int("h"))
+	}
+}
+
+func TestParseArgsErrors(t *testing.T) {
+	tr, _ := lookupTransform("resize")
+
+	for _, raw := range []map[string]string{
+		{"width": "100"},
+		{"w": "abc"},
+		{"w": "-1"},
+		// the check of the whole set of parameters
+		{},
+	} {
+		if _, err := tr.parseArgs(raw); err == nil {
+			t.Errorf("%v: expected an error", raw)
+		}
+	}
+}
+
+func TestParseStep(t *testing.T) {
+	s, err := parseStep("small=resize:w=100,h=50@input")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Name != "small" || s.Transform != "resize" {
+		t.Errorf("got name '%s' and transform '%s'", s.Name, s.Transform)
+	}
+	if s.Params["w"] != "100" || s.Params["h"] != "50" {
+		t.Errorf("got params %v", s.Params)
+	}
+	if len(s.Inputs) != 1 || s.Inputs[0] != "input" {
+		t.Errorf("got inputs %v", s.Inputs)
+	}
+}
+
+func TestParseStepErrors(t *testing.T) {
+	for _, spec := range []string{
+		"resize:w",
+		"resize:=100",
+		"resize:w=100,w=200",
+	} {
+		if _, err := parseStep(spec); err == nil {
+			t.Errorf("%s: expected an error", spec)
+		}
+	}
+}
+
+func TestWatershedMarkersSizeMismatch(t *testing.T) {
+	tr, _ := lookupTransform("watershed-markers")
+	a, err := tr.parseArgs(map[string]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img := image.NewGray(image.Rect(0, 0, 8, 8))
+	markers := image.NewGray(image.Rect(0, 0, 4, 4))
+
+	if _, err := tr.operation(a)(img, markers); err == nil {
+		t.Error("expected an error")
+	}
+
+	// images of the same size but with different origins are aligned
+	markers = image.NewGray(image.Rect(10, 10, 18, 18))
+	markers.SetGray(12, 12, color.Gray{255})
+	if _, err := tr.operation(a)(img, markers); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGradientDirection(t *testing.T) {
+	tr, _ := lookupTransform("gradient-direction")
+
+	// vertical bands: the luminance increases towards the right, i.e. the
+	// gradients have an angle of 0, shown in cyan
+	img := image.NewGray(image.Rect(0, 0, 8, 8))
+	for y := 0; y < 8; y++ {
+		for x := 4; x < 8; x++ {
+			img.SetGray(x, y, color.Gray{255})
+		}
+	}
+
+	for _, operator := range []string{"central", "sobel"} {
+		a, err := tr.parseArgs(map[string]string{"operator": operator})
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := tr.operation(a)(img)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if r, g, b, _ := out.At(4, 4).RGBA(); r != 0 || g != 0xFFFF || b != 0xFFFF {
+			t.Errorf("%s: got (%d, %d, %d) on the edge, want cyan", operator, r, g, b)
+		}
+		if r, g, b, _ := out.At(1, 4).RGBA(); r|g|b != 0 {
+			t.Errorf("%s: got (%d, %d, %d) on a flat area, want black", operator, r, g, b)
+		}
+	}
+}
+
+func TestMatchTemplateThreshold(t *testing.T) {
+	dir := tempDir(t)
+	writeImages(t, dir, "black.png")
+	template := filepath.Join(dir, "black.png")
+
+	tr, _ := lookupTransform("match-template")
+
+	for _, raw := range []map[string]string{
+		{"template": template, "method": "ssd", "threshold": "-0.5"},
+		{"template": template, "method": "ncc", "threshold": "-0.5"},
+		{"template": template, "method": "zncc", "threshold": "1.5"},
+	} {
+		if _, err := tr.parseArgs(raw); err == nil {
+			t.Errorf("%v: expected an error", raw)
+		}
+	}
+	if _, err := tr.parseArgs(map[string]string{"template": template, "threshold": "-0.5"}); err != nil {
+		t.Errorf("zncc with a negative threshold: %v", err)
+	}
+
+	// a gray square and a black one over a white background: the SSD score
+	// is 0 on the black square and about 0.25 on the gray one, above the
+	// default threshold of SSD
+	img := image.NewGray(image.Rect(0, 0, 12, 6))
+	for i := range img.Pix {
+		img.Pix[i] = 255
+	}
+	for y := 1; y < 5; y++ {
+		for x := 0; x < 4; x++ {
+			img.SetGray(x, y, color.Gray{128})
+			img.SetGray(x+6, y, color.Gray{0})
+		}
+	}
+
+	a, err := tr.parseArgs(map[string]string{"template": template, "method": "ssd"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := tr.operation(a)(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	isRed := func(x, y int) bool {
+		r, g, b, _ := out.At(x, y).RGBA()
+		return r == 0xFFFF && g == 0 && b == 0
+	}
+	if !isRed(6, 1) {
+		t.Error("the black square wasn't matched")
+	}
+	if isRed(0, 1) {
+		t.Error("the gray square was matched")
+	}
+}